@@ -4,28 +4,41 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"reflect"
 	"slices"
 	"sort"
-	"strconv"
-	"strings"
+	"time"
 
-	"github.com/jftuga/geodist"
 	log "github.com/sirupsen/logrus"
 )
 
 const adsbapiv2 = "https://adsbexchange-com1.p.rapidapi.com/v2/%s"
 const adsbapikeyheader = "x-rapidapi-key"
 
+const (
+	// How long to reuse a response if CacheTTLSeconds isn't set
+	adsbDefaultCacheTTL = 60 * time.Second
+	// Request limits if RequestsPerMinute and RequestBurst aren't set
+	adsbDefaultRequestsPerMinute = 10
+	adsbDefaultRequestBurst      = 3
+)
+
 type ADSBExchangeAnnotator struct {
 	Annotator
 	Module
 	// APIKey provided by signing up at ADSB-Exchange.
 	APIKey string `jsonschema:"required" default:"example_key"`
-	// Geolocation to use for distance calculations (LAT,LON).
+	// Geolocation to use for distance calculations (LAT,LON) if the station isn't in Stations.
 	ReferenceGeolocation string `default:"35.6244416,139.7753782"`
+	// Reuse the response for an aircraft for this many seconds instead of asking again.
+	CacheTTLSeconds int64 `jsonschema:"default=60" default:"60"`
+	// Also save responses in the database so they're reused after a restart.
+	PersistCache bool `jsonschema:"default=false" default:"false"`
+	// Most requests to make per minute (-1 for no limit), to stay within your RapidAPI quota. Lookups over the limit are skipped.
+	RequestsPerMinute float64 `jsonschema:"default=10" default:"10"`
+	// How many requests can be made at once before RequestsPerMinute applies.
+	RequestBurst int `jsonschema:"default=3" default:"3"`
 	// Only provide these fields to future steps.
 	SelectedFields []string
 }
@@ -96,6 +109,25 @@ type ADSBExchangeCalculated struct {
 	AircraftGeolocationLongitude float64 `ap:"AircraftLongitude"`
 	AircraftDistanceKm           float64 `ap:"AircraftDistanceKm"`
 	AircraftDistanceMi           float64 `ap:"AircraftDistanceMi"`
+	// Degrees from true north, from the station or reference geolocation
+	AircraftBearingDegrees float64 `ap:"AircraftBearingDegrees"`
+	// Degrees above the horizon, from the station or reference geolocation
+	AircraftElevationAngleDegrees float64 `ap:"AircraftElevationAngleDegrees"`
+	// Name of the station distances are from, if it's in Stations
+	ReferenceStation string
+}
+
+// Returns the rate limiter for API requests, or nil if RequestsPerMinute is
+// negative
+func (a ADSBExchangeAnnotator) Limiter() *TokenBucket {
+	perMinute, burst := a.RequestsPerMinute, a.RequestBurst
+	if perMinute == 0 {
+		perMinute = adsbDefaultRequestsPerMinute
+	}
+	if burst <= 0 {
+		burst = adsbDefaultRequestBurst
+	}
+	return RateLimiter(a.Name(), perMinute, burst)
 }
 
 // Wrapper around the SingleAircraftPositionByRegistration API
@@ -105,15 +137,22 @@ func (a ADSBExchangeAnnotator) SingleAircraftPositionByRegistration(reg string)
 		return ac, err
 	}
 	req.Header.Add(adsbapikeyheader, a.APIKey)
-	client := &http.Client{}
+
+	ttl := time.Duration(a.CacheTTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = adsbDefaultCacheTTL
+	}
 
 	log.Debug(Aside("%s: making call to ads-b exchange", a.Name()))
-	resp, err := client.Do(req)
+	body, err := CachedHTTPRequest(req, HTTPCacheOptions{
+		Key:     req.URL.String(),
+		TTL:     ttl,
+		Persist: a.PersistCache,
+		Limiter: a.Limiter(),
+	})
 	if err != nil {
-		log.Error(Attention(fmt.Sprintf("%s", err)))
+		return ac, err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
 	err = json.Unmarshal(body, &ac)
 	if err != nil {
 		return ac, err
@@ -127,17 +166,10 @@ func (a ADSBExchangeAnnotator) SingleAircraftPositionByRegistration(reg string)
 }
 
 func (a ADSBExchangeAnnotator) Annotate(m APMessage) (APMessage, error) {
-	if a.ReferenceGeolocation == "" {
-		log.Info(Note("%s enabled but geolocation not set, using '0,0'", a.Name()))
-		a.ReferenceGeolocation = "0,0"
-	}
-	coords := strings.Split(a.ReferenceGeolocation, ",")
-	if len(coords) != 2 {
-		return m, fmt.Errorf("%s: geolocation coordinates are not in the format 'LAT,LON'", a.Name())
+	ref, err := ReferenceLocationFor(m, a.ReferenceGeolocation)
+	if err != nil {
+		return m, fmt.Errorf("%s: %w", a.Name(), err)
 	}
-	olat, _ := strconv.ParseFloat(coords[0], 64)
-	olon, _ := strconv.ParseFloat(coords[1], 64)
-	origin := geodist.Coord{Lat: olat, Lon: olon}
 	tailcode := GetAPMessageCommonFieldAsString(m, "TailCode")
 	if tailcode == "" {
 		log.Debug(Note("%s: did not find a tail code in message, this is normal.", a.Name()))
@@ -155,22 +187,19 @@ func (a ADSBExchangeAnnotator) Annotate(m APMessage) (APMessage, error) {
 	apm := FormatAsAPMessage(pos, a.Name())
 	var alat, alon float64
 	alat, alon = pos.Aircraft[0].Latitude, pos.Aircraft[0].Longitude
-	aircraft := geodist.Coord{Lat: alat, Lon: alon}
-	if alat == 0.0 && alon == 0.0 {
-		// Make the origin the aircraft so distance is zero, which is more
-		// helpful than if the origin was Null Island.
-		origin = aircraft
-	}
-	mi, km, err := geodist.VincentyDistance(origin, aircraft)
+	rel, err := ref.PositionOf(alat, alon, float64(pos.Aircraft[0].AltimeterGeometricFeet))
 	if err != nil {
-		log.Warn(Attention("%s: error calculating distance: %s", a.Name(), err))
+		log.Warn(Attention("%s: %s", a.Name(), err))
 	}
 	calc := ADSBExchangeCalculated{
-		AircraftGeolocation:          fmt.Sprintf("%f,%f", alat, alon),
-		AircraftGeolocationLatitude:  alat,
-		AircraftGeolocationLongitude: alon,
-		AircraftDistanceKm:           km,
-		AircraftDistanceMi:           mi,
+		AircraftGeolocation:           fmt.Sprintf("%f,%f", alat, alon),
+		AircraftGeolocationLatitude:   alat,
+		AircraftGeolocationLongitude:  alon,
+		AircraftDistanceKm:            rel.DistanceKm,
+		AircraftDistanceMi:            rel.DistanceMi,
+		AircraftBearingDegrees:        rel.BearingDegrees,
+		AircraftElevationAngleDegrees: rel.ElevationAngleDegrees,
+		ReferenceStation:              ref.Name,
 	}
 	calcapm := FormatAsAPMessage(calc, a.Name())
 	apm = MergeAPMessages(apm, calcapm)
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// Aircraft databases from the config, keyed by path
+	aircraftDatabases     = map[string]*AircraftDatabase{}
+	aircraftDatabasesLock sync.Mutex
+)
+
+type AircraftDatabaseAnnotator struct {
+	Annotator
+	Module
+	// Path to an aircraft database, either tar1090-db's aircraft.csv or basic-ac-db.json (optionally gzipped). It's loaded at startup.
+	DatabaseFile string `jsonschema:"required,example=./aircraft.csv.gz,example=./basic-ac-db.json.gz" default:"./aircraft.csv.gz"`
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+// An aircraft from the database
+type AircraftRecord struct {
+	ICAOHex      string
+	Registration string
+	// ICAO type designator, like B738
+	TypeDesignator string
+	// Like BOEING 737-800
+	Description     string
+	OwnerOperator   string
+	ManufactureYear string
+	Military        bool
+}
+
+type AircraftDatabase struct {
+	ByHex          map[string]*AircraftRecord
+	ByRegistration map[string]*AircraftRecord
+}
+
+func (a AircraftDatabaseAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a AircraftDatabaseAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, AircraftDatabaseAnnotator{})
+}
+
+func (a AircraftDatabaseAnnotator) GetDefaultFields() (s []string) {
+	for f := range FormatAsAPMessage(AircraftRecord{}, "AircraftDatabase") {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Returns the database at path, loading it if it hasn't been yet
+func GetAircraftDatabase(path string) (*AircraftDatabase, error) {
+	aircraftDatabasesLock.Lock()
+	defer aircraftDatabasesLock.Unlock()
+	if db, ok := aircraftDatabases[path]; ok {
+		return db, nil
+	}
+	start := time.Now()
+	db, err := LoadAircraftDatabase(path)
+	if err != nil {
+		// Don't try again for every message
+		aircraftDatabases[path] = &AircraftDatabase{}
+		return nil, fmt.Errorf("unable to load aircraft database %s: %w", path, err)
+	}
+	aircraftDatabases[path] = db
+	log.Info(Success("loaded %d aircraft from %s in %s", len(db.ByHex), path, time.Since(start).Round(time.Millisecond)))
+	return db, nil
+}
+
+// Loads a tar1090-db CSV (hex;reg;type;flags;desc;year;ownop) or basic-ac-db
+// JSON lines file, either of which may be gzipped
+func LoadAircraftDatabase(path string) (*AircraftDatabase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if magic, _ := r.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = bufio.NewReader(gz)
+	}
+	db := &AircraftDatabase{
+		ByHex:          map[string]*AircraftRecord{},
+		ByRegistration: map[string]*AircraftRecord{},
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			var ac *AircraftRecord
+			if strings.HasPrefix(line, "{") {
+				ac = ParseBasicACDBLine(line)
+			} else {
+				ac = ParseTar1090DBLine(line)
+			}
+			if ac != nil {
+				db.ByHex[ac.ICAOHex] = ac
+				if ac.Registration != "" {
+					db.ByRegistration[NormalizeAircraftRegistration(ac.Registration)] = ac
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Parses a line of tar1090-db's aircraft.csv, like
+// a0b1c2;N123AB;B738;00;BOEING 737-800;2015;United Airlines;
+func ParseTar1090DBLine(line string) *AircraftRecord {
+	f := strings.Split(line, ";")
+	if len(f) < 3 || len(f[0]) != 6 {
+		return nil
+	}
+	field := func(i int) string {
+		if i < len(f) {
+			return strings.TrimSpace(f[i])
+		}
+		return ""
+	}
+	return &AircraftRecord{
+		ICAOHex:        strings.ToUpper(f[0]),
+		Registration:   field(1),
+		TypeDesignator: field(2),
+		// The first flag is military, then interesting, PIA and LADD
+		Military:        strings.HasPrefix(field(3), "1"),
+		Description:     field(4),
+		ManufactureYear: field(5),
+		OwnerOperator:   field(6),
+	}
+}
+
+// Parses a line of basic-ac-db.json
+func ParseBasicACDBLine(line string) *AircraftRecord {
+	var b struct {
+		ICAO         string `json:"icao"`
+		Registration string `json:"reg"`
+		ICAOType     string `json:"icaotype"`
+		Year         string `json:"year"`
+		Manufacturer string `json:"manufacturer"`
+		Model        string `json:"model"`
+		OwnOp        string `json:"ownop"`
+		Military     bool   `json:"mil"`
+	}
+	if err := json.Unmarshal([]byte(line), &b); err != nil || len(b.ICAO) != 6 {
+		return nil
+	}
+	return &AircraftRecord{
+		ICAOHex:         strings.ToUpper(b.ICAO),
+		Registration:    b.Registration,
+		TypeDesignator:  b.ICAOType,
+		Description:     strings.TrimSpace(b.Manufacturer + " " + b.Model),
+		OwnerOperator:   b.OwnOp,
+		ManufactureYear: b.Year,
+		Military:        b.Military,
+	}
+}
+
+// Finds an aircraft by ICAO hex, then by registration
+func (db *AircraftDatabase) Lookup(hex, registration string) (*AircraftRecord, bool) {
+	if ac, ok := db.ByHex[strings.ToUpper(hex)]; ok && hex != "" {
+		return ac, true
+	}
+	ac, ok := db.ByRegistration[NormalizeAircraftRegistration(registration)]
+	return ac, ok && registration != ""
+}
+
+func (a AircraftDatabaseAnnotator) Annotate(m APMessage) (APMessage, error) {
+	db, err := GetAircraftDatabase(a.DatabaseFile)
+	if err != nil {
+		return m, err
+	}
+	tail := GetAPMessageCommonFieldAsString(m, "TailCode")
+	ac, ok := db.Lookup(GetAPMessageCommonFieldAsString(m, "ICAOHex"), tail)
+	if !ok {
+		log.Debug(Aside("%s: aircraft not found", a.Name()))
+		return m, nil
+	}
+	apm := FormatAsAPMessage(*ac, "AircraftDatabase")
+	// Remove all but any selected fields
+	if len(a.SelectedFields) > 0 {
+		for field := range apm {
+			if !slices.Contains(a.SelectedFields, field) {
+				delete(apm, field)
+			}
+		}
+	}
+	// Messages without a registration can get one from the ICAO hex
+	if tail == "" && ac.Registration != "" {
+		apm[ACARSProcessorPrefix+"TailCode"] = ac.Registration
+	}
+	return MergeAPMessages(m, apm), nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	//go:embed data/airlines.csv
+	airlinesCSV []byte
+	// Built-in airline database
+	Airlines = LoadBuiltinAirlines()
+	// Airline files from the config, keyed by path, loaded the first time
+	// they're used
+	airlineFiles     = map[string]AirlineDatabase{}
+	airlineFilesLock sync.Mutex
+	// UAL123 or UAL0123A
+	icaoFlightNumberRegex = regexp.MustCompile(`^([A-Z]{3})0*(\d{1,4}[A-Z]?)$`)
+	// UA0123 or B6123
+	iataFlightNumberRegex = regexp.MustCompile(`^([A-Z0-9]{2})0*(\d{1,4}[A-Z]?)$`)
+)
+
+type AirlineAnnotator struct {
+	Annotator
+	Module
+	// Split the flight number into airline and number, and add the airline's IATA and ICAO codes, name, callsign and country. Runs whenever this section is configured unless set to false.
+	Enabled *bool `jsonschema:"default=true" default:"true"`
+	// CSV file with the columns IATA,ICAO,Name,Callsign,Country to add to or replace airlines in the built-in database.
+	AirlineFile string `jsonschema:"example=./airlines.csv" default:"./airlines.csv"`
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+type Airline struct {
+	IATA     string
+	ICAO     string
+	Name     string
+	Callsign string
+	Country  string
+}
+
+type AirlineDatabase struct {
+	ByIATA map[string]Airline
+	ByICAO map[string]Airline
+}
+
+// Fields added under ACARSProcessor
+type AirlineAnnotatorResult struct {
+	AirlineIATA     string
+	AirlineICAO     string
+	AirlineName     string
+	AirlineCallsign string
+	AirlineCountry  string
+	// The flight number with the IATA designator, like UA123
+	FlightNumberIATA string
+	// The flight number with the ICAO designator, like UAL123
+	FlightNumberICAO string
+	// The flight number without the airline or padding, like 123
+	FlightNumberNumeric string
+}
+
+func LoadBuiltinAirlines() AirlineDatabase {
+	db, err := LoadAirlines(bytes.NewReader(airlinesCSV))
+	if err != nil {
+		log.Error(Attention("unable to load built-in airline database: %s", err))
+	}
+	return db
+}
+
+// Reads airlines from CSV with the columns IATA,ICAO,Name,Callsign,Country.
+// A header row is skipped. If more than one airline has the same code, the
+// last one wins.
+func LoadAirlines(r io.Reader) (db AirlineDatabase, err error) {
+	db = AirlineDatabase{ByIATA: map[string]Airline{}, ByICAO: map[string]Airline{}}
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = 5
+	c.TrimLeadingSpace = true
+	records, err := c.ReadAll()
+	if err != nil {
+		return db, err
+	}
+	for _, rec := range records {
+		if rec[0] == "IATA" {
+			continue
+		}
+		db.Add(Airline{
+			IATA:     strings.ToUpper(rec[0]),
+			ICAO:     strings.ToUpper(rec[1]),
+			Name:     rec[2],
+			Callsign: rec[3],
+			Country:  rec[4],
+		})
+	}
+	return db, nil
+}
+
+func (db AirlineDatabase) Add(a Airline) {
+	if a.IATA != "" {
+		db.ByIATA[a.IATA] = a
+	}
+	if a.ICAO != "" {
+		db.ByICAO[a.ICAO] = a
+	}
+}
+
+// Returns the built-in database with the airlines from file added to it. If
+// the file can't be loaded, the built-in database is used.
+func AirlinesWithFile(file string) AirlineDatabase {
+	if file == "" {
+		return Airlines
+	}
+	airlineFilesLock.Lock()
+	defer airlineFilesLock.Unlock()
+	if db, ok := airlineFiles[file]; ok {
+		return db
+	}
+	db, err := LoadAirlineFile(file)
+	if err != nil {
+		log.Error(Attention("unable to load airlines from %s, using the built-in database: %s", file, err))
+		db = Airlines
+	}
+	airlineFiles[file] = db
+	return db
+}
+
+func LoadAirlineFile(file string) (db AirlineDatabase, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return db, err
+	}
+	defer f.Close()
+	extra, err := LoadAirlines(f)
+	if err != nil {
+		return db, err
+	}
+	db = AirlineDatabase{ByIATA: maps.Clone(Airlines.ByIATA), ByICAO: maps.Clone(Airlines.ByICAO)}
+	maps.Copy(db.ByIATA, extra.ByIATA)
+	maps.Copy(db.ByICAO, extra.ByICAO)
+	log.Info(Success("loaded airlines from %s", file))
+	return db, nil
+}
+
+// Splits a flight number like UA0123 or UAL123 into the airline and number,
+// ok is false if the airline isn't in the database
+func (db AirlineDatabase) ParseFlightNumber(flight string) (r AirlineAnnotatorResult, ok bool) {
+	flight = strings.ToUpper(strings.TrimSpace(flight))
+	var a Airline
+	var number string
+	if m := icaoFlightNumberRegex.FindStringSubmatch(flight); m != nil {
+		a, ok = db.ByICAO[m[1]]
+		number = m[2]
+	}
+	if m := iataFlightNumberRegex.FindStringSubmatch(flight); !ok && m != nil {
+		a, ok = db.ByIATA[m[1]]
+		number = m[2]
+	}
+	if !ok {
+		return r, false
+	}
+	r = AirlineAnnotatorResult{
+		AirlineIATA:         a.IATA,
+		AirlineICAO:         a.ICAO,
+		AirlineName:         a.Name,
+		AirlineCallsign:     a.Callsign,
+		AirlineCountry:      a.Country,
+		FlightNumberNumeric: number,
+	}
+	if a.IATA != "" {
+		r.FlightNumberIATA = a.IATA + number
+	}
+	if a.ICAO != "" {
+		r.FlightNumberICAO = a.ICAO + number
+	}
+	return r, true
+}
+
+func (a AirlineAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a AirlineAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, AirlineAnnotator{})
+}
+
+func (a AirlineAnnotator) GetDefaultFields() (s []string) {
+	for f := range FormatAsAPMessage(AirlineAnnotatorResult{}, strings.TrimSuffix(ACARSProcessorPrefix, ".")) {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func (a AirlineAnnotator) Annotate(m APMessage) (APMessage, error) {
+	if a.Enabled != nil && !*a.Enabled {
+		return m, nil
+	}
+	flight := GetAPMessageCommonFieldAsString(m, "FlightNumber")
+	if flight == "" {
+		return m, nil
+	}
+	r, ok := AirlinesWithFile(a.AirlineFile).ParseFlightNumber(flight)
+	if !ok {
+		return m, nil
+	}
+	apm := FormatAsAPMessage(r, strings.TrimSuffix(ACARSProcessorPrefix, "."))
+	// Remove all but any selected fields
+	if len(a.SelectedFields) > 0 {
+		for field := range apm {
+			if !slices.Contains(a.SelectedFields, field) {
+				delete(apm, field)
+			}
+		}
+	}
+	return MergeAPMessages(m, apm), nil
+}
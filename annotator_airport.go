@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/jftuga/geodist"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	//go:embed data/airports.csv
+	airportsCSV []byte
+	// Built-in airport database
+	Airports = LoadBuiltinAirports()
+	// Airport files from the config, keyed by path, loaded the first time
+	// they're used
+	airportFiles     = map[string]AirportDatabase{}
+	airportFilesLock sync.Mutex
+	// RWY 28L, RW09 or RUNWAY 4
+	runwayRegex = regexp.MustCompile(`\b(?:RWY|RW|RUNWAY)\s?(\d{1,2}[LRC]?)\b`)
+	// DCT VIPPS, DIRECT TO VIPPS or DIR VIPPS
+	directToRegex = regexp.MustCompile(`\b(?:DCT|DIRECT|DIR)\s+(?:TO\s+)?([A-Z]{2,5})\b`)
+	// Named fixes and navaids in routes, like VIPPS or SFO, but not airways
+	// like J211
+	waypointRegex = regexp.MustCompile(`^[A-Z]{2,5}$`)
+	// Words before an airport that mean it's the origin or destination
+	originKeywords      = []string{"FROM", "DEP", "DEPT", "DPT", "ORIG", "ORG", "DA"}
+	destinationKeywords = []string{"TO", "DEST", "DES", "DST", "ARR", "ARRV", "AA"}
+	// Words that look like waypoints in routes
+	notWaypoints = []string{"DCT", "DIR", "TO"}
+)
+
+// How far from the station airports can be to estimate the position from if
+// EstimatePositionMaxDistanceKm isn't set
+const airportDefaultEstimatePositionMaxDistanceKm = 400
+
+type AirportAnnotator struct {
+	Annotator
+	Module
+	// Find airports, runways and waypoints in the message text and guess the flight's origin and destination. Runs whenever this section is configured unless set to false.
+	Enabled *bool `jsonschema:"default=true" default:"true"`
+	// CSV file in the OurAirports airports.csv format (https://ourairports.com/data/) to add to or replace airports in the built-in database.
+	AirportFile string `jsonschema:"example=./airports.csv" default:"./airports.csv"`
+	// If no earlier annotator added the aircraft's position, use the mentioned airport closest to the station that heard the message as ACARSProcessor.AircraftLatitude and AircraftLongitude. This lets geofence and distance filters work without ADS-B.
+	EstimatePosition bool `jsonschema:"default=false" default:"false"`
+	// Only estimate the position from airports within this many kilometers of the station (-1 for no limit).
+	EstimatePositionMaxDistanceKm float64 `jsonschema:"default=400" default:"400"`
+	// Geolocation to measure from (LAT,LON) if the station isn't in Stations.
+	ReferenceGeolocation string `default:"35.6244416,139.7753782"`
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+type Airport struct {
+	ICAO string
+	IATA string
+	// Like large_airport or small_airport
+	Type          string
+	Name          string
+	City          string
+	Country       string
+	Latitude      float64
+	Longitude     float64
+	ElevationFeet int64
+}
+
+type AirportDatabase struct {
+	ByICAO map[string]Airport
+	ByIATA map[string]Airport
+}
+
+// Fields added under ACARSProcessor
+type AirportAnnotatorResult struct {
+	// ICAO codes of the airports in the message, in the order they're
+	// mentioned, separated by commas
+	MentionedAirports string
+	// Where the flight is probably from, going by decoded fields, words like
+	// FROM or DEP, or pairs of airports like KSFO-KJFK
+	OriginAirport *Airport
+	// Where the flight is probably going, found the same way as the origin
+	DestinationAirport *Airport
+	// Runways mentioned in the message, like 28L, separated by commas
+	Runways string
+	// Waypoints in the message or the decoded route, separated by commas
+	Waypoints string
+}
+
+// Fields added under ACARSProcessor when the position is estimated
+type AirportPositionEstimate struct {
+	AircraftLatitude       float64
+	AircraftLongitude      float64
+	AircraftGeolocation    string
+	AircraftDistanceKm     float64
+	AircraftDistanceMi     float64
+	AircraftBearingDegrees float64
+	// ICAO code of the airport the position is from
+	AircraftPositionFromAirport string
+}
+
+func LoadBuiltinAirports() AirportDatabase {
+	db, err := LoadAirports(bytes.NewReader(airportsCSV))
+	if err != nil {
+		log.Error(Attention("unable to load built-in airport database: %s", err))
+	}
+	return db
+}
+
+// Reads airports from CSV in the OurAirports format. Only the ident, type,
+// name, latitude_deg, longitude_deg, elevation_ft, iso_country, municipality,
+// gps_code, icao_code and iata_code columns are used, and closed airports,
+// heliports and the like are skipped.
+func LoadAirports(r io.Reader) (db AirportDatabase, err error) {
+	db = AirportDatabase{ByICAO: map[string]Airport{}, ByIATA: map[string]Airport{}}
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = -1
+	header, err := c.Read()
+	if err != nil {
+		return db, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"ident", "name", "latitude_deg", "longitude_deg"} {
+		if _, ok := columns[required]; !ok {
+			return db, fmt.Errorf("missing column %s", required)
+		}
+	}
+	for {
+		rec, err := c.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return db, err
+		}
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		switch get("type") {
+		case "closed", "heliport", "balloonport", "seaplane_base":
+			continue
+		}
+		a := Airport{
+			ICAO:    strings.ToUpper(get("ident")),
+			IATA:    strings.ToUpper(get("iata_code")),
+			Type:    get("type"),
+			Name:    get("name"),
+			City:    get("municipality"),
+			Country: get("iso_country"),
+		}
+		// Newer files have the real ICAO code separately from the ident
+		for _, code := range []string{get("icao_code"), get("gps_code")} {
+			if len(code) == 4 {
+				a.ICAO = strings.ToUpper(code)
+				break
+			}
+		}
+		a.Latitude, _ = strconv.ParseFloat(get("latitude_deg"), 64)
+		a.Longitude, _ = strconv.ParseFloat(get("longitude_deg"), 64)
+		a.ElevationFeet, _ = strconv.ParseInt(get("elevation_ft"), 10, 64)
+		db.Add(a)
+	}
+	return db, nil
+}
+
+func (db AirportDatabase) Add(a Airport) {
+	if a.ICAO != "" {
+		db.ByICAO[a.ICAO] = a
+	}
+	if a.IATA != "" {
+		db.ByIATA[a.IATA] = a
+	}
+}
+
+// Returns the built-in database with the airports from file added to it. If
+// the file can't be loaded, the built-in database is used.
+func AirportsWithFile(file string) AirportDatabase {
+	if file == "" {
+		return Airports
+	}
+	airportFilesLock.Lock()
+	defer airportFilesLock.Unlock()
+	if db, ok := airportFiles[file]; ok {
+		return db
+	}
+	db, err := LoadAirportFile(file)
+	if err != nil {
+		log.Error(Attention("unable to load airports from %s, using the built-in database: %s", file, err))
+		db = Airports
+	}
+	airportFiles[file] = db
+	return db
+}
+
+func LoadAirportFile(file string) (db AirportDatabase, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return db, err
+	}
+	defer f.Close()
+	extra, err := LoadAirports(f)
+	if err != nil {
+		return db, err
+	}
+	db = AirportDatabase{ByICAO: maps.Clone(Airports.ByICAO), ByIATA: maps.Clone(Airports.ByIATA)}
+	maps.Copy(db.ByICAO, extra.ByICAO)
+	maps.Copy(db.ByIATA, extra.ByIATA)
+	log.Info(Success("loaded %d airports from %s", len(extra.ByICAO), file))
+	return db, nil
+}
+
+// Looks up an ICAO or IATA code
+func (db AirportDatabase) Find(code string) (a Airport, ok bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if a, ok = db.ByICAO[code]; ok {
+		return a, true
+	}
+	a, ok = db.ByIATA[code]
+	return a, ok
+}
+
+// Splits text into words on anything that isn't a letter or number
+func airportWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Returns the airports mentioned in the text in order, and the origin and
+// destination if words like FROM and TO or a pair like KSFO-KJFK or
+// KSFOKJFK give them away
+func (db AirportDatabase) Scan(text string) (mentioned []Airport, origin, destination *Airport) {
+	var pair []Airport
+	words := airportWords(text)
+	// The last airport and where it was, for pairs like KSFO KJFK or KSFO TO
+	// KJFK
+	var last *Airport
+	lastIndex := -1
+	for i, w := range words {
+		// Times and dates are often stuck on the end, like KPHXKLAX0820
+		letters := strings.TrimRightFunc(w, unicode.IsDigit)
+		var found []Airport
+		switch len(letters) {
+		case 4:
+			if a, ok := db.ByICAO[letters]; ok {
+				found = append(found, a)
+			}
+		case 8:
+			a, ok1 := db.ByICAO[letters[:4]]
+			b, ok2 := db.ByICAO[letters[4:]]
+			if ok1 && ok2 {
+				found = append(found, a, b)
+				if pair == nil {
+					pair = found
+				}
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+		if i > 0 && len(found) == 1 {
+			a := found[0]
+			switch {
+			case slices.Contains(originKeywords, words[i-1]) && origin == nil:
+				origin = &a
+			case slices.Contains(destinationKeywords, words[i-1]) && destination == nil:
+				destination = &a
+				if origin == nil && lastIndex == i-2 && last.ICAO != a.ICAO {
+					origin = last
+				}
+			case lastIndex == i-1 && pair == nil && last.ICAO != a.ICAO:
+				pair = []Airport{*last, a}
+			}
+		}
+		for _, a := range found {
+			if !slices.ContainsFunc(mentioned, func(m Airport) bool { return m.ICAO == a.ICAO }) {
+				mentioned = append(mentioned, a)
+			}
+		}
+		last, lastIndex = &found[len(found)-1], i
+	}
+	if pair != nil {
+		if origin == nil {
+			origin = &pair[0]
+		}
+		if destination == nil {
+			destination = &pair[1]
+		}
+	}
+	return mentioned, origin, destination
+}
+
+// Returns the runways mentioned in the text, like 28L
+func Runways(text string) (runways []string) {
+	for _, m := range runwayRegex.FindAllStringSubmatch(text, -1) {
+		rwy := m[1]
+		if len(rwy) == 1 || unicode.IsLetter(rune(rwy[1])) {
+			rwy = "0" + rwy
+		}
+		if n, _ := strconv.Atoi(rwy[:2]); n < 1 || n > 36 {
+			continue
+		}
+		if !slices.Contains(runways, rwy) {
+			runways = append(runways, rwy)
+		}
+	}
+	return runways
+}
+
+// Returns the waypoints from the decoded fields and the route, and ones the
+// text says to go direct to
+func (db AirportDatabase) Waypoints(m APMessage, text string) (waypoints []string) {
+	add := func(w string) {
+		_, isAirport := db.ByICAO[w]
+		if waypointRegex.MatchString(w) && !isAirport && !slices.Contains(notWaypoints, w) &&
+			!slices.Contains(waypoints, w) {
+			waypoints = append(waypoints, w)
+		}
+	}
+	add(GetAPMessageCommonFieldAsString(m, "Decoded.Waypoint"))
+	add(GetAPMessageCommonFieldAsString(m, "Decoded.NextWaypoint"))
+	for _, w := range airportWords(GetAPMessageCommonFieldAsString(m, "Decoded.Route")) {
+		add(w)
+	}
+	for _, match := range directToRegex.FindAllStringSubmatch(text, -1) {
+		add(match[1])
+	}
+	return waypoints
+}
+
+func (a AirportAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a AirportAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, AirportAnnotator{})
+}
+
+func (a AirportAnnotator) GetDefaultFields() (s []string) {
+	prefix := strings.TrimSuffix(ACARSProcessorPrefix, ".")
+	r := AirportAnnotatorResult{OriginAirport: &Airport{}, DestinationAirport: &Airport{}}
+	for f := range MergeAPMessages(FormatAsAPMessage(r, prefix), FormatAsAPMessage(AirportPositionEstimate{}, prefix)) {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Returns the airport closest to the station that heard the message, as long
+// as it's within EstimatePositionMaxDistanceKm
+func (a AirportAnnotator) EstimatePositionFrom(m APMessage, airports []Airport) (e AirportPositionEstimate, ok bool, err error) {
+	ref, err := ReferenceLocationFor(m, a.ReferenceGeolocation)
+	if err != nil || ref.Coord == (geodist.Coord{}) {
+		return e, false, err
+	}
+	maxKm := a.EstimatePositionMaxDistanceKm
+	if maxKm == 0 {
+		maxKm = airportDefaultEstimatePositionMaxDistanceKm
+	}
+	closest := math.Inf(1)
+	for _, ap := range airports {
+		rel, err := ref.PositionOf(ap.Latitude, ap.Longitude, float64(ap.ElevationFeet))
+		if err != nil {
+			return e, false, err
+		}
+		if rel.DistanceKm >= closest ||
+			(maxKm > 0 && rel.DistanceKm > maxKm) {
+			continue
+		}
+		closest = rel.DistanceKm
+		e = AirportPositionEstimate{
+			AircraftLatitude:            ap.Latitude,
+			AircraftLongitude:           ap.Longitude,
+			AircraftGeolocation:         fmt.Sprintf("%f,%f", ap.Latitude, ap.Longitude),
+			AircraftDistanceKm:          rel.DistanceKm,
+			AircraftDistanceMi:          rel.DistanceMi,
+			AircraftBearingDegrees:      rel.BearingDegrees,
+			AircraftPositionFromAirport: ap.ICAO,
+		}
+		ok = true
+	}
+	return e, ok, nil
+}
+
+func (a AirportAnnotator) Annotate(m APMessage) (APMessage, error) {
+	if a.Enabled != nil && !*a.Enabled {
+		return m, nil
+	}
+	text := GetAPMessageCommonFieldAsString(m, "MessageText")
+	db := AirportsWithFile(a.AirportFile)
+	mentioned, origin, destination := db.Scan(text)
+	// Decoded fields are better than guesses
+	if ap, ok := db.Find(GetAPMessageCommonFieldAsString(m, "Decoded.Origin")); ok {
+		origin = &ap
+	}
+	if ap, ok := db.Find(GetAPMessageCommonFieldAsString(m, "Decoded.Destination")); ok {
+		destination = &ap
+	}
+	r := AirportAnnotatorResult{
+		OriginAirport:      origin,
+		DestinationAirport: destination,
+		Runways:            strings.Join(Runways(text), ","),
+		Waypoints:          strings.Join(db.Waypoints(m, text), ","),
+	}
+	var codes []string
+	for _, ap := range mentioned {
+		codes = append(codes, ap.ICAO)
+	}
+	r.MentionedAirports = strings.Join(codes, ",")
+	if reflect.DeepEqual(r, AirportAnnotatorResult{}) {
+		return m, nil
+	}
+	apm := FormatAsAPMessage(r, strings.TrimSuffix(ACARSProcessorPrefix, "."))
+
+	hasPosition := GetAPMessageCommonFieldAsFloat64(m, "AircraftLatitude") != 0 ||
+		GetAPMessageCommonFieldAsFloat64(m, "AircraftLongitude") != 0
+	if a.EstimatePosition && !hasPosition {
+		// The decoded airports might not be in the text
+		for _, ap := range []*Airport{origin, destination} {
+			if ap != nil && !slices.ContainsFunc(mentioned, func(m Airport) bool { return m.ICAO == ap.ICAO }) {
+				mentioned = append(mentioned, *ap)
+			}
+		}
+		e, ok, err := a.EstimatePositionFrom(m, mentioned)
+		if err != nil {
+			return m, err
+		}
+		if ok {
+			apm = MergeAPMessages(apm, FormatAsAPMessage(e, strings.TrimSuffix(ACARSProcessorPrefix, ".")))
+		}
+	}
+	// Remove all but any selected fields
+	if len(a.SelectedFields) > 0 {
+		for field := range apm {
+			if !slices.Contains(a.SelectedFields, field) {
+				delete(apm, field)
+			}
+		}
+	}
+	return MergeAPMessages(m, apm), nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ClearancePrefix = ACARSProcessorPrefix + "Clearance"
+
+// Kinds of clearances, in the order they're checked
+var clearanceTypeOrder = []string{"Oceanic", "DCL", "PDC"}
+
+var (
+	clearanceTypeRegexes = map[string]*regexp.Regexp{
+		// ARINC 623 oceanic clearances (CLX) and free text ones
+		"Oceanic": regexp.MustCompile(`\b(CLX|OCL|OCEANIC (CLEARANCE|CLRNCE|CLR))\b`),
+		// ARINC 623 departure clearances (CLD), mostly in Europe
+		"DCL": regexp.MustCompile(`\b(CLD|DCL)\b`),
+		// Pre-departure clearances from airlines' ops, mostly in the US
+		"PDC": regexp.MustCompile(`\b(PDC|PRE-?DEPARTURE CLEARANCE|DEPARTURE CLEARANCE)\b`),
+	}
+	// Aircraft asking for a clearance rather than getting one
+	clearanceRequestRegex = regexp.MustCompile(`\bREQ(UEST)?\s+(PDC|DCL|CLX|OCEANIC|CLEARANCE|CLRNCE)\b`)
+	// CLEARED TO KLAX or CLRD TO KJFK
+	clearedToRegex = regexp.MustCompile(`\b(?:CLEARED|CLRD)\s+TO\s+(?:THE\s+)?([A-Z]{4})\b`)
+	// CLEARED SSTIK3 DEPARTURE or CLEARED TO KLAX VIA THE TRUKN2 DEPARTURE
+	sidDepartureRegex = regexp.MustCompile(`\b([A-Z]{3,5}\d[A-Z]?)\s+(?:DEPARTURE|DEPART|DEP|SID)\b`)
+	// SID: TRUKN2 or VIA CPT5J
+	sidRegex = regexp.MustCompile(`\b(?:SID|VIA)\s*:?\s+(?:THE\s+)?([A-Z]{3,5}\d[A-Z]?)\b`)
+	// PYE TRANSITION
+	transitionRegex = regexp.MustCompile(`\b([A-Z]{3,5})\s+(?:TRANSITION|TRANS|TRSN)\b`)
+	// OFF 27R or RWY 28L
+	clearanceRunwayRegex = regexp.MustCompile(`\b(?:OFF|RWY|RW|RUNWAY)\s?(\d{2}[LRC]?)\b`)
+	// SQUAWK 4621 or XPDR 4621
+	squawkRegex = regexp.MustCompile(`\b(?:SQUAWK|SQWK|SQK|XPDR|XPNDR|TRANSPONDER|SSR(?:\s+CODE)?)\s*:?\s*([0-7]{4})\b`)
+	// MAINT 5000FT, MNTN F350 or INITIAL CLIMB FL060
+	altitudeRegex = regexp.MustCompile(`\b(?:MAINT(?:AIN)?|MNTN|CLIMB(?:\s+AND\s+MAINTAIN)?|INITIAL(?:\s+CLIMB|\s+ALT(?:ITUDE)?)?|CLB\s+TO|ALT)\s*:?\s+(FL\s?|F)?(\d{2,5})(\s?FT)?(\s+MIN)?\b`)
+	// EXP FL350, EXPECT 350 10 MIN AFT DP or EXPECT 10 MIN AFTER DEP FL360
+	expectedAltitudeRegex = regexp.MustCompile(`\b(?:EXP(?:ECT)?|EXPT)\s+(?:\d{1,2}\s+MIN(?:UTES)?\s+AFT(?:ER)?\s+(?:DP|DEP|DEPARTURE)\s+)?(FL\s?|F)?(\d{2,5})(\s?FT)?(\s+MIN)?\b`)
+	// DPFRQ 120.9, DEP FREQ 125.35 or NEXT FREQ 121.980
+	departureFrequencyRegex = regexp.MustCompile(`\b(?:DPFRQ|DEP(?:ARTURE)?\s*(?:FREQ(?:UENCY)?|FRQ)|NEXT\s+FREQ)\s*:?\s*(1[1-3]\d\.\d{1,3})\b`)
+	// ROUTE: KSFO SSTIK3 PYE J1 KLAX
+	routeLineRegex = regexp.MustCompile(`(?m)^[ \t]*(?:FILED ROUTE|CLEARED ROUTE|ROUTE|RTE)[ \t]*[:\-]?[ \t]*(\S.*?)[ \t]*$`)
+	// A line from one airport to another, like KSFO SSTIK3 PYE J1 KLAX
+	airportToAirportRegex = regexp.MustCompile(`(?m)^[ \t]*([A-Z]{4}(?:[ \t]+\S+)+[ \t]+[A-Z]{4})[ \t]*$`)
+	// NAT A, TRACK A or TRK A
+	natTrackRegex    = regexp.MustCompile(`\b(?:NAT|TRACK|TRK)\s+([A-Z])\b`)
+	randomRouteRegex = regexp.MustCompile(`\bRANDOM\s+(ROUTE|RTE)\b`)
+	// VIA DINIM, the oceanic entry point
+	oceanicViaRegex = regexp.MustCompile(`\bVIA\s+([A-Z]{5}|\d{2,4}N\d{3,5}W)\b`)
+	// FM DINIM/1416 or FROM DINIM/1416Z
+	oceanicEntryRegex = regexp.MustCompile(`\b(?:FM|FROM)\s+([A-Z]{5}|\d{2,4}N\d{3,5}W)/(\d{4})Z?\b`)
+	// M083, M.83 or MACH .83
+	machRegex = regexp.MustCompile(`\b(?:M|MACH\s+)0?\.?(\d{2,3})\b`)
+)
+
+type ClearanceAnnotator struct {
+	Annotator
+	Module
+	// Parse pre-departure (PDC), departure (DCL) and oceanic clearances in the message text into fields under ACARSProcessor.Clearance, and add ACARSProcessor.ClearanceType. Runs whenever this section is configured unless set to false.
+	Enabled *bool `jsonschema:"default=true" default:"true"`
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+// Fields parsed from a clearance. Only the fields the clearance has will be
+// set.
+type Clearance struct {
+	// PDC, DCL or Oceanic
+	Type string `ap:"ClearanceType"`
+	// The airport the aircraft is cleared to
+	Destination string
+	SID         string
+	Transition  string
+	Runway      string
+	Squawk      string
+	// The altitude to climb to or maintain
+	AltitudeFeet int64
+	// The altitude to expect later, usually some minutes after departure
+	ExpectedAltitudeFeet int64
+	// In MHz
+	DepartureFrequency string
+	Route              string
+	// The North Atlantic Track letter, or RANDOM for a random route
+	NATTrack          string
+	OceanicEntryPoint string
+	// HHMM UTC
+	OceanicEntryTime string
+	Mach             float64
+}
+
+func (a ClearanceAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a ClearanceAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, ClearanceAnnotator{})
+}
+
+func (a ClearanceAnnotator) GetDefaultFields() (s []string) {
+	for f := range FormatAsAPMessage(Clearance{}, ClearancePrefix) {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Returns the first altitude re finds in text, skipping times like EXP 10
+// MIN AFT DP. Altitudes with FT are already in feet, like MAINT 700 FT
+func clearanceAltitude(re *regexp.Regexp, text string) (feet int64, ok bool) {
+	for _, m := range re.FindAllStringSubmatch(text, -1) {
+		if m[4] != "" {
+			continue
+		}
+		if m[3] != "" {
+			if feet, err := strconv.ParseInt(m[2], 10, 64); err == nil && feet > 0 {
+				return feet, true
+			}
+			continue
+		}
+		if feet, ok = ParseAltitude(m[2]); ok && feet > 0 {
+			return feet, true
+		}
+	}
+	return 0, false
+}
+
+// Parses a PDC, DCL or oceanic clearance, ok is false if the text isn't one
+func ParseClearance(text string) (c Clearance, ok bool) {
+	if clearanceRequestRegex.MatchString(text) {
+		return c, false
+	}
+	for _, t := range clearanceTypeOrder {
+		if clearanceTypeRegexes[t].MatchString(text) {
+			c.Type = t
+			break
+		}
+	}
+	if m := natTrackRegex.FindStringSubmatch(text); m != nil {
+		c.NATTrack = m[1]
+	} else if randomRouteRegex.MatchString(text) {
+		c.NATTrack = "RANDOM"
+	}
+	if m := oceanicEntryRegex.FindStringSubmatch(text); m != nil {
+		c.OceanicEntryPoint, c.OceanicEntryTime = m[1], m[2]
+	}
+	// Oceanic clearances don't always say so, but nothing else has tracks
+	// and entry times
+	if c.Type == "" && c.NATTrack != "" && c.OceanicEntryPoint != "" {
+		c.Type = "Oceanic"
+	}
+	if c.Type == "" {
+		return c, false
+	}
+
+	if m := clearedToRegex.FindStringSubmatch(text); m != nil {
+		c.Destination = m[1]
+	}
+	if m := squawkRegex.FindStringSubmatch(text); m != nil {
+		c.Squawk = m[1]
+	}
+	if feet, ok := clearanceAltitude(altitudeRegex, text); ok {
+		c.AltitudeFeet = feet
+	}
+	if feet, ok := clearanceAltitude(expectedAltitudeRegex, text); ok {
+		c.ExpectedAltitudeFeet = feet
+	}
+	if m := departureFrequencyRegex.FindStringSubmatch(text); m != nil {
+		c.DepartureFrequency = m[1]
+	}
+
+	if c.Type == "Oceanic" {
+		c.parseOceanicRoute(text)
+		if m := machRegex.FindStringSubmatch(text); m != nil {
+			n, _ := strconv.ParseFloat(m[1], 64)
+			c.Mach = n / math.Pow10(len(m[1]))
+		}
+	} else {
+		if m := sidDepartureRegex.FindStringSubmatch(text); m != nil {
+			c.SID = m[1]
+		} else if m := sidRegex.FindStringSubmatch(text); m != nil {
+			c.SID = m[1]
+		}
+		if m := transitionRegex.FindStringSubmatch(text); m != nil {
+			c.Transition = m[1]
+		}
+		if m := clearanceRunwayRegex.FindStringSubmatch(text); m != nil {
+			c.Runway = m[1]
+		}
+		if m := routeLineRegex.FindStringSubmatch(text); m != nil {
+			c.Route = m[1]
+		} else if m := airportToAirportRegex.FindStringSubmatch(text); m != nil {
+			c.Route = strings.Join(strings.Fields(m[1]), " ")
+		}
+	}
+
+	// Requests, readbacks and the like just mention the clearance
+	parsed := c
+	parsed.Type = ""
+	if parsed == (Clearance{}) {
+		return c, false
+	}
+	return c, true
+}
+
+// The route is what's between VIA and the entry time or altitude, like
+// VIA ETIKI NAT A ETIKI 55/20 56/30 57/40 PRAWN FM ETIKI/1245 MNTN F350
+func (c *Clearance) parseOceanicRoute(text string) {
+	via := oceanicViaRegex.FindStringSubmatchIndex(text)
+	if via == nil {
+		return
+	}
+	if c.OceanicEntryPoint == "" {
+		c.OceanicEntryPoint = text[via[2]:via[3]]
+	}
+	end := len(text)
+	for _, re := range []*regexp.Regexp{oceanicEntryRegex, altitudeRegex} {
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			if m[0] >= via[1] && m[0] < end {
+				end = m[0]
+			}
+		}
+	}
+	route := text[via[1]:end]
+	route = natTrackRegex.ReplaceAllString(route, "")
+	route = randomRouteRegex.ReplaceAllString(route, "")
+	c.Route = strings.Join(strings.Fields(route), " ")
+}
+
+func (a ClearanceAnnotator) Annotate(m APMessage) (APMessage, error) {
+	if a.Enabled != nil && !*a.Enabled {
+		return m, nil
+	}
+	c, ok := ParseClearance(GetAPMessageCommonFieldAsString(m, "MessageText"))
+	if !ok {
+		return m, nil
+	}
+	apm := FormatAsAPMessage(c, ClearancePrefix)
+	// Remove all but any selected fields
+	if len(a.SelectedFields) > 0 {
+		for field := range apm {
+			if !slices.Contains(a.SelectedFields, field) {
+				delete(apm, field)
+			}
+		}
+	}
+	return MergeAPMessages(m, apm), nil
+}
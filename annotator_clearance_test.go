@@ -0,0 +1,147 @@
+package main
+
+import "testing"
+
+func TestParseClearance(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		ok   bool
+		want Clearance
+	}{
+		{
+			name: "US PDC",
+			text: "PDC 1234 UAL1234 KSFO\n" +
+				"CLEARED TO KLAX VIA THE TRUKN2 DEPARTURE SNS TRANSITION\n" +
+				"ROUTE: KSFO TRUKN2 SNS J501 KLAX\n" +
+				"MAINT 5000FT EXPECT FL350 10 MIN AFT DP\n" +
+				"DPFRQ 135.1 SQUAWK 4621",
+			ok: true,
+			want: Clearance{
+				Type:                 "PDC",
+				Destination:          "KLAX",
+				SID:                  "TRUKN2",
+				Transition:           "SNS",
+				Squawk:               "4621",
+				AltitudeFeet:         5000,
+				ExpectedAltitudeFeet: 35000,
+				DepartureFrequency:   "135.1",
+				Route:                "KSFO TRUKN2 SNS J501 KLAX",
+			},
+		},
+		{
+			name: "US PDC with route line and expected altitude after the time",
+			text: ".KSFO PRE-DEPARTURE CLEARANCE\n" +
+				"AAL2345 B738/L P1530\n" +
+				"KSFO SSTIK4 PYE J1 KLAX\n" +
+				"CLIMB VIA SID EXPECT 10 MIN AFTER DEP FL360\n" +
+				"DEP FREQ 120.9 XPDR 3312",
+			ok: true,
+			want: Clearance{
+				Type:                 "PDC",
+				Squawk:               "3312",
+				ExpectedAltitudeFeet: 36000,
+				DepartureFrequency:   "120.9",
+				Route:                "KSFO SSTIK4 PYE J1 KLAX",
+			},
+		},
+		{
+			name: "PDC with a low altitude in feet",
+			text: "PDC 0412 SKW5012 KASE\n" +
+				"CLEARED TO KDEN VIA THE LINDZ9 DEPARTURE\n" +
+				"MAINT 700 FT EXP 10 MIN AFT DP\n" +
+				"SQUAWK 2214",
+			ok: true,
+			want: Clearance{
+				Type:         "PDC",
+				Destination:  "KDEN",
+				SID:          "LINDZ9",
+				Squawk:       "2214",
+				AltitudeFeet: 700,
+			},
+		},
+		{
+			name: "DCL",
+			text: "/HDQDLUA.DC1/CLD 1526 231016 EDDF PDC 514\n" +
+				"DLH4TK CLRD TO KORD OFF 25C VIA TOBAK7M\n" +
+				"SQUAWK 1000 ADT MDI NEXT FREQ 121.805 ATIS J\n" +
+				"INITIAL CLIMB FL070",
+			ok: true,
+			want: Clearance{
+				Type:               "DCL",
+				Destination:        "KORD",
+				SID:                "TOBAK7M",
+				Runway:             "25C",
+				Squawk:             "1000",
+				AltitudeFeet:       7000,
+				DepartureFrequency: "121.805",
+			},
+		},
+		{
+			name: "CLX on a NAT track",
+			text: "CLX 1259 231016 CZQX CLRNCE 345\n" +
+				"BAW117 CLRD TO KJFK VIA ETIKI\n" +
+				"NAT A ETIKI 55/20 56/30 57/40 PRAWN\n" +
+				"FM ETIKI/1245 MNTN F350 M084\n" +
+				"END OF MESSAGE",
+			ok: true,
+			want: Clearance{
+				Type:              "Oceanic",
+				Destination:       "KJFK",
+				AltitudeFeet:      35000,
+				Route:             "ETIKI 55/20 56/30 57/40 PRAWN",
+				NATTrack:          "A",
+				OceanicEntryPoint: "ETIKI",
+				OceanicEntryTime:  "1245",
+				Mach:              0.84,
+			},
+		},
+		{
+			name: "CLX on a random route",
+			text: "CLX 0930 231016 EGGX CLRNCE 123\n" +
+				"AAL100 CLRD TO KJFK VIA 56N010W\n" +
+				"RANDOM ROUTE\n" +
+				"56N010W 56N020W 55N030W 53N040W 50N050W\n" +
+				"FM 56N010W/1030 MNTN F370 M.83",
+			ok: true,
+			want: Clearance{
+				Type:              "Oceanic",
+				Destination:       "KJFK",
+				AltitudeFeet:      37000,
+				Route:             "56N010W 56N020W 55N030W 53N040W 50N050W",
+				NATTrack:          "RANDOM",
+				OceanicEntryPoint: "56N010W",
+				OceanicEntryTime:  "1030",
+				Mach:              0.83,
+			},
+		},
+		{
+			name: "oceanic clearance without CLX",
+			text: "UAL900 TRACK C FROM 53N015W/0212Z MACH .82 FL360",
+			ok:   true,
+			want: Clearance{
+				Type:              "Oceanic",
+				NATTrack:          "C",
+				OceanicEntryPoint: "53N015W",
+				OceanicEntryTime:  "0212",
+				Mach:              0.82,
+			},
+		},
+		{name: "request", text: "REQUEST PDC KSFO"},
+		{name: "request for oceanic", text: "REQ OCEANIC CLEARANCE ETIKI 1245 F350 M084"},
+		{name: "mention only", text: "PDC"},
+		{name: "not a clearance", text: "ETA KSFO 1523 FUEL 123"},
+		{name: "empty", text: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseClearance(tt.text)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (parsed %+v)", ok, tt.ok, got)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parsed %+v\nwant   %+v", got, tt.want)
+			}
+		})
+	}
+}
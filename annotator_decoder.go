@@ -0,0 +1,170 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var DecodedPrefix = ACARSProcessorPrefix + "Decoded"
+
+type DecoderAnnotator struct {
+	Annotator
+	Module
+	// Decode label-specific message formats (like OOOI times, position reports, flight plans, CPDLC and ADS-C) into fields. Runs whenever this section is configured unless set to false.
+	Enabled *bool `jsonschema:"default=true" default:"true"`
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+// Fields decoded from the message text. Only the fields the message's format
+// has will be set.
+type DecodedMessage struct {
+	// What kind of message was decoded, like "OOOI" or "Position"
+	Type string
+	// Format-specific kind of message, like "OFF" for an OOOI off report
+	Subtype            string
+	SubtypeDescription string
+	Latitude           float64
+	Longitude          float64
+	AltitudeFeet       int64
+	Origin             string
+	Destination        string
+	// OOOI times, HHMM UTC
+	OutTime string
+	OffTime string
+	OnTime  string
+	InTime  string
+	// Estimated time of arrival, HHMM UTC
+	ETA string
+	// Waypoint the aircraft reported at
+	Waypoint     string
+	NextWaypoint string
+	Route        string
+	// CPDLC and ADS-C messages
+	ARINC622 ARINC622Message
+}
+
+// Decodes the text of a message with a particular label, ok is false if the
+// text isn't in the format the decoder expects.
+type LabelDecoder func(text string) (d DecodedMessage, ok bool)
+
+// Decoders to try for each label, in order. The first one that succeeds is
+// used.
+var LabelDecoders = map[string][]LabelDecoder{
+	"QP": {DecodeOOOI("OUT")},
+	"QQ": {DecodeOOOI("OFF")},
+	"QR": {DecodeOOOI("ON")},
+	"QS": {DecodeOOOI("IN")},
+	"QT": {DecodeOOOI("RETURN IN")},
+	"16": {DecodeDecimalPositionReport},
+	"20": {DecodePOSReport},
+	"H1": {DecodeH1FlightPlan, DecodeH1PositionReport},
+	"5Z": {DecodeAirlineOps},
+	// ARINC 622 ATS messages, uplinks start with A and downlinks with B
+	"AA": {DecodeARINC622(true)},
+	"A6": {DecodeARINC622(true)},
+	"BA": {DecodeARINC622(false)},
+	"B6": {DecodeARINC622(false)},
+}
+
+func (a DecoderAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a DecoderAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, DecoderAnnotator{})
+}
+
+func (a DecoderAnnotator) GetDefaultFields() (s []string) {
+	for f := range FormatAsAPMessage(DecodedMessage{}, DecodedPrefix) {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func (a DecoderAnnotator) Annotate(m APMessage) (APMessage, error) {
+	if a.Enabled != nil && !*a.Enabled {
+		return m, nil
+	}
+	label := GetAPMessageCommonFieldAsString(m, "Label")
+	text := strings.TrimSpace(GetAPMessageCommonFieldAsString(m, "MessageText"))
+	if text == "" {
+		return m, nil
+	}
+	for _, decode := range LabelDecoders[label] {
+		d, ok := decode(text)
+		if !ok {
+			continue
+		}
+		apm := FormatAsAPMessage(d, DecodedPrefix)
+		// Remove all but any selected fields
+		if len(a.SelectedFields) > 0 {
+			for field := range apm {
+				if !slices.Contains(a.SelectedFields, field) {
+					delete(apm, field)
+				}
+			}
+		}
+		return MergeAPMessages(m, apm), nil
+	}
+	return m, nil
+}
+
+var (
+	// N 44.203 or W086.546
+	decimalCoordinateRegex = regexp.MustCompile(`^([NSEW]) ?(\d{1,3}\.\d+)$`)
+	// Degrees, minutes and tenths of minutes, N38123 or W121456
+	minutesCoordinateRegex = regexp.MustCompile(`^([NSEW]) ?(\d{2,3})(\d{2})(\d)$`)
+)
+
+// Parses coordinates in the formats position reports use, ok is false if it
+// couldn't be parsed or is out of range
+func ParseCoordinate(s string) (c float64, ok bool) {
+	s = strings.TrimSpace(s)
+	var hemisphere string
+	if m := decimalCoordinateRegex.FindStringSubmatch(s); m != nil {
+		hemisphere = m[1]
+		c, _ = strconv.ParseFloat(m[2], 64)
+	} else if m := minutesCoordinateRegex.FindStringSubmatch(s); m != nil {
+		hemisphere = m[1]
+		degrees, _ := strconv.ParseFloat(m[2], 64)
+		minutes, _ := strconv.ParseFloat(m[3]+"."+m[4], 64)
+		if minutes >= 60 {
+			return 0, false
+		}
+		c = degrees + minutes/60
+	} else {
+		return 0, false
+	}
+	limit := 180.0
+	if hemisphere == "N" || hemisphere == "S" {
+		limit = 90
+	}
+	if hemisphere == "S" || hemisphere == "W" {
+		c = -c
+	}
+	if math.Abs(c) > limit {
+		return 0, false
+	}
+	return math.Round(c*1e5) / 1e5, true
+}
+
+// Parses an altitude that's either in feet or a flight level (3 digits or
+// less), ok is false if it isn't a number
+func ParseAltitude(s string) (feet int64, ok bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "FL")
+	alt, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || alt < 0 {
+		return 0, false
+	}
+	if len(s) <= 3 {
+		alt *= 100
+	}
+	return alt, true
+}
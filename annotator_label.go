@@ -0,0 +1,143 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const unknownLabelCategory = "Unknown"
+
+var (
+	labelResultPrefix = strings.TrimSuffix(ACARSProcessorPrefix, ".")
+	//go:embed data/labels.json
+	labelsJSON []byte
+	// Built-in descriptions of ACARS labels, keyed by label
+	LabelTable = LoadLabelTable(labelsJSON)
+	// H1 messages say which terminal they're to or from with a sublabel at the
+	// start, like #M1B or - #DFB
+	sublabelRegex = regexp.MustCompile(`^(?:- )?#([A-Z0-9]{2})[A-Z]`)
+)
+
+type LabelAnnotator struct {
+	Annotator
+	Module
+	// Add a description and category (like OOOI, Weather, Free text, Maintenance or Position) for each message's label. Runs whenever this section is configured unless set to false.
+	Enabled *bool `jsonschema:"default=true" default:"true"`
+	// Add to or replace entries in the built-in label table, keyed by label. Sublabels are merged with the built-in ones.
+	Labels map[string]LabelDefinition
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+type LabelDefinition struct {
+	Description string
+	// Like OOOI, Weather, Free text, Maintenance, Position, ATC, Link or Operations
+	Category string
+	// Definitions for sublabels (like M1 in #M1B), which take precedence over the label's
+	Sublabels map[string]LabelDefinition `json:",omitempty"`
+}
+
+// Fields added under ACARSProcessor
+type LabelAnnotatorResult struct {
+	Sublabel         string
+	LabelDescription string
+	LabelCategory    string
+}
+
+func LoadLabelTable(b []byte) (t map[string]LabelDefinition) {
+	if err := json.Unmarshal(b, &t); err != nil {
+		log.Error(Attention("unable to load built-in label table: %s", err))
+	}
+	return t
+}
+
+func (a LabelAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a LabelAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, LabelAnnotator{})
+}
+
+func (a LabelAnnotator) GetDefaultFields() (s []string) {
+	for f := range FormatAsAPMessage(LabelAnnotatorResult{}, labelResultPrefix) {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Returns the definition of a label, with the configured definitions taking
+// precedence over the built-in ones
+func (a LabelAnnotator) Lookup(label string) (d LabelDefinition, ok bool) {
+	d, ok = LabelTable[label]
+	o, overridden := a.Labels[label]
+	if !overridden {
+		return d, ok
+	}
+	if o.Description != "" {
+		d.Description = o.Description
+	}
+	if o.Category != "" {
+		d.Category = o.Category
+	}
+	if len(o.Sublabels) > 0 {
+		sublabels := map[string]LabelDefinition{}
+		for s, sd := range d.Sublabels {
+			sublabels[s] = sd
+		}
+		for s, sd := range o.Sublabels {
+			sublabels[s] = sd
+		}
+		d.Sublabels = sublabels
+	}
+	return d, true
+}
+
+func (a LabelAnnotator) Annotate(m APMessage) (APMessage, error) {
+	if a.Enabled != nil && !*a.Enabled {
+		return m, nil
+	}
+	label := GetAPMessageCommonFieldAsString(m, "Label")
+	if label == "" {
+		return m, nil
+	}
+	r := LabelAnnotatorResult{
+		Sublabel:      GetAPMessageCommonFieldAsString(m, "Sublabel"),
+		LabelCategory: unknownLabelCategory,
+	}
+	if r.Sublabel == "" {
+		if s := sublabelRegex.FindStringSubmatch(GetAPMessageCommonFieldAsString(m, "MessageText")); s != nil {
+			r.Sublabel = s[1]
+		}
+	}
+	if d, ok := a.Lookup(label); ok {
+		r.LabelDescription = d.Description
+		if d.Category != "" {
+			r.LabelCategory = d.Category
+		}
+		if s, ok := d.Sublabels[r.Sublabel]; ok {
+			r.LabelDescription = d.Description + ": " + s.Description
+			if s.Category != "" {
+				r.LabelCategory = s.Category
+			}
+		}
+	}
+	apm := FormatAsAPMessage(r, labelResultPrefix)
+	// Remove all but any selected fields
+	if len(a.SelectedFields) > 0 {
+		for field := range apm {
+			if !slices.Contains(a.SelectedFields, field) {
+				delete(apm, field)
+			}
+		}
+	}
+	return MergeAPMessages(m, apm), nil
+}
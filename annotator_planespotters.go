@@ -0,0 +1,232 @@
+// Adapted from https://github.com/vvanouytsel/jetspotter/blob/79e729862e5468fed4512e8478e7b9eb1595a008/internal/planespotter/planespotter.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// Used if URL isn't set
+	planespottersDefaultURL = "https://api.planespotters.net/pub/photos"
+	// How long to keep lookups if the TTLs aren't set
+	planespottersDefaultCacheTTL         = 86400 * time.Second
+	planespottersDefaultNegativeCacheTTL = 3600 * time.Second
+	// Request limits if RequestsPerMinute and RequestBurst aren't set
+	planespottersDefaultRequestsPerMinute = 30
+	planespottersDefaultRequestBurst      = 5
+)
+
+var planespottersClient = &http.Client{Timeout: 10 * time.Second}
+
+type PlanespottersAnnotator struct {
+	Annotator
+	Module
+	// Add a photo of the aircraft from planespotters.net, looked up by ICAO hex and then tail code. Runs whenever this section is configured unless set to false.
+	Enabled *bool `jsonschema:"default=true" default:"true"`
+	// Base URL of the planespotters.net photos API, or something that serves the same responses.
+	URL string `jsonschema:"example=https://api.planespotters.net/pub/photos" default:"https://api.planespotters.net/pub/photos"`
+	// How long to keep a photo before looking it up again.
+	CacheTTLSeconds int64 `jsonschema:"default=86400" default:"86400"`
+	// How long to remember that an aircraft has no photos before looking it up again.
+	NegativeCacheTTLSeconds int64 `jsonschema:"default=3600" default:"3600"`
+	// Most requests to make per minute (-1 for no limit). Lookups over the limit are skipped.
+	RequestsPerMinute float64 `jsonschema:"default=30" default:"30"`
+	// How many requests can be made at once before RequestsPerMinute applies.
+	RequestBurst int `jsonschema:"default=5" default:"5"`
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+type Size struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type Thumbnail struct {
+	Src  string `json:"src"`
+	Size Size   `json:"size"`
+}
+
+type Image struct {
+	ID             string    `json:"id"`
+	Thumbnail      Thumbnail `json:"thumbnail"`
+	ThumbnailLarge Thumbnail `json:"thumbnail_large"`
+	Link           string    `json:"link"`
+	Photographer   string    `json:"photographer"`
+}
+
+type ImagesData struct {
+	Images []Image `json:"photos"`
+}
+
+// Fields added under ACARSProcessor
+type PlanespottersAnnotatorResult struct {
+	ThumbnailLink string
+	ImageLink     string
+	// Credit for the photo, which planespotters.net asks to be shown with it
+	Photographer string
+}
+
+// A cached lookup, which is kept even if there weren't any photos so the API
+// isn't asked again until the negative TTL expires
+type PlanespottersPhoto struct {
+	gorm.Model
+	// Like hex/A1B2C3 or reg/N123AB
+	Query                        string `gorm:"uniqueIndex;size:64"`
+	Found                        bool
+	FetchedAt                    time.Time
+	PlanespottersAnnotatorResult `gorm:"embedded"`
+}
+
+func (a PlanespottersAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a PlanespottersAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, PlanespottersAnnotator{})
+}
+
+func (a PlanespottersAnnotator) GetDefaultFields() (s []string) {
+	for f := range FormatAsAPMessage(PlanespottersAnnotatorResult{}, strings.TrimSuffix(ACARSProcessorPrefix, ".")) {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Returns the cached photo for a query if it hasn't expired, otherwise asks
+// the API and caches the answer
+func (a PlanespottersAnnotator) Photo(kind, id string) (p PlanespottersPhoto, err error) {
+	query := kind + "/" + strings.ToUpper(id)
+	if db.Where("query = ?", query).Limit(1).Find(&p).RowsAffected > 0 {
+		ttl := time.Duration(a.CacheTTLSeconds) * time.Second
+		if ttl <= 0 {
+			ttl = planespottersDefaultCacheTTL
+		}
+		if !p.Found {
+			ttl = time.Duration(a.NegativeCacheTTLSeconds) * time.Second
+			if ttl <= 0 {
+				ttl = planespottersDefaultNegativeCacheTTL
+			}
+		}
+		if time.Since(p.FetchedAt) < ttl {
+			return p, nil
+		}
+	}
+	img, err := a.GetImage(kind, id)
+	if err != nil {
+		return p, err
+	}
+	p.Query = query
+	p.FetchedAt = time.Now()
+	p.Found = img != nil
+	p.PlanespottersAnnotatorResult = PlanespottersAnnotatorResult{}
+	if img != nil {
+		p.ThumbnailLink = img.ThumbnailLarge.Src
+		p.ImageLink = img.Link
+		p.Photographer = img.Photographer
+	}
+	if err := db.Save(&p).Error; err != nil {
+		log.Warn(Attention("%s: unable to cache photo for %s: %s", a.Name(), query, err))
+	}
+	return p, nil
+}
+
+// Returns the rate limiter for requests to base, or nil if RequestsPerMinute
+// is negative
+func (a PlanespottersAnnotator) Limiter(base string) *TokenBucket {
+	perMinute, burst := a.RequestsPerMinute, a.RequestBurst
+	if perMinute == 0 {
+		perMinute = planespottersDefaultRequestsPerMinute
+	}
+	if burst <= 0 {
+		burst = planespottersDefaultRequestBurst
+	}
+	return RateLimiter(a.Name()+base, perMinute, burst)
+}
+
+// Asks the API for photos of an aircraft by hex or reg, returning nil if it
+// doesn't have any
+func (a PlanespottersAnnotator) GetImage(kind, id string) (image *Image, err error) {
+	var images ImagesData
+	base := a.URL
+	if base == "" {
+		base = planespottersDefaultURL
+	}
+	u := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(base, "/"), kind, url.PathEscape(id))
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", WebhookUserAgent)
+	if l := a.Limiter(base); l != nil && !l.Allow() {
+		return nil, ErrRateLimited
+	}
+	res, err := planespottersClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", res.Status, u)
+	}
+	if err := json.NewDecoder(res.Body).Decode(&images); err != nil {
+		return nil, err
+	}
+	if len(images.Images) == 0 {
+		return nil, nil
+	}
+	return &images.Images[0], nil
+}
+
+func (a PlanespottersAnnotator) Annotate(m APMessage) (APMessage, error) {
+	if a.Enabled != nil && !*a.Enabled {
+		return m, nil
+	}
+	lookups := [][2]string{
+		{"hex", GetAPMessageCommonFieldAsString(m, "ICAOHex")},
+		{"reg", GetAPMessageCommonFieldAsString(m, "TailCode")},
+	}
+	var p PlanespottersPhoto
+	for _, l := range lookups {
+		if l[1] == "" {
+			continue
+		}
+		var err error
+		p, err = a.Photo(l[0], l[1])
+		if err != nil {
+			return m, fmt.Errorf("error looking up photo by %s %s: %w", l[0], l[1], err)
+		}
+		if p.Found {
+			break
+		}
+	}
+	if !p.Found {
+		log.Debug(Aside("%s: no photo found", a.Name()))
+		return m, nil
+	}
+	apm := FormatAsAPMessage(p.PlanespottersAnnotatorResult, strings.TrimSuffix(ACARSProcessorPrefix, "."))
+	// Remove all but any selected fields
+	if len(a.SelectedFields) > 0 {
+		for field := range apm {
+			if !slices.Contains(a.SelectedFields, field) {
+				delete(apm, field)
+			}
+		}
+	}
+	return MergeAPMessages(m, apm), nil
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	readsbFormatSBS  = "sbs"
+	readsbFormatJSON = "json"
+	// How long to wait before reconnecting to a feed
+	readsbReconnectDelay = 10 * time.Second
+	// How long to remember aircraft if MaxAgeSeconds isn't set
+	readsbDefaultMaxAge = 300 * time.Second
+)
+
+var (
+	// Connected feeds, keyed by address
+	readsbFeeds     = map[string]*ReadsbFeed{}
+	readsbFeedsLock sync.Mutex
+)
+
+type ReadsbAnnotator struct {
+	Annotator
+	Module
+	// Address of readsb's (or dump1090's) SBS output, usually port 30003, or JSON output (--net-json-port).
+	Address string `jsonschema:"required,example=readsb:30003" default:"readsb:30003"`
+	// Either sbs (BaseStation) or json.
+	Format string `jsonschema:"enum=sbs,enum=json,default=sbs" default:"sbs"`
+	// Forget aircraft that haven't been heard from in this many seconds.
+	MaxAgeSeconds int64 `jsonschema:"default=300" default:"300"`
+	// Geolocation to use for distance calculations (LAT,LON) if the station isn't in Stations.
+	ReferenceGeolocation string `default:"35.6244416,139.7753782"`
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+// The latest state of an aircraft heard on a feed
+type ReadsbAircraft struct {
+	ICAOHex  string
+	Callsign string
+	// Only the JSON feed has registrations
+	Registration            string
+	Latitude                float64
+	Longitude               float64
+	BarometricAltitudeFeet  int64
+	GroundSpeedKnots        float64
+	TrackDegrees            float64
+	VerticalRateFeetMinute  int64
+	Squawk                  string
+	Emergency               bool
+	OnGround                bool
+	SecondsSinceLastMessage float64
+	// Which of ICAOHex, Registration or Callsign found the aircraft
+	MatchedBy    string
+	lastSeen     time.Time
+	lastPosition time.Time
+}
+
+// Fields from the aircraft's position, only added if the feed has one so
+// positions from earlier annotators aren't replaced
+type ReadsbCalculated struct {
+	AircraftLatitude              float64 `ap:"AircraftLatitude"`
+	AircraftLongitude             float64 `ap:"AircraftLongitude"`
+	AircraftBarometerAltitudeFeet int64   `ap:"AircraftBarometerAltitudeFeet"`
+	AircraftGeolocation           string  `ap:"AircraftGeolocation"`
+	AircraftDistanceKm            float64 `ap:"AircraftDistanceKm"`
+	AircraftDistanceMi            float64 `ap:"AircraftDistanceMi"`
+	// Degrees from true north, from the station or reference geolocation
+	AircraftBearingDegrees float64 `ap:"AircraftBearingDegrees"`
+	// Degrees above the horizon, from the station or reference geolocation
+	AircraftElevationAngleDegrees float64 `ap:"AircraftElevationAngleDegrees"`
+	// Name of the station distances are from, if it's in Stations
+	ReferenceStation string
+	// How old the position was when the message was annotated
+	PositionAgeSeconds float64
+}
+
+// A connection to a feed and the aircraft heard on it
+type ReadsbFeed struct {
+	sync.RWMutex
+	Address string
+	Format  string
+	MaxAge  time.Duration
+	// Keyed by ICAO hex
+	Aircraft map[string]*ReadsbAircraft
+	// ICAO hex, keyed by callsign and normalized registration
+	ByCallsign     map[string]string
+	ByRegistration map[string]string
+}
+
+func (a ReadsbAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a ReadsbAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, ReadsbAnnotator{})
+}
+
+func (a ReadsbAnnotator) GetDefaultFields() (s []string) {
+	ac := FormatAsAPMessage(ReadsbAircraft{}, a.Name())
+	c := FormatAsAPMessage(ReadsbCalculated{}, a.Name())
+	for f := range MergeAPMessages(ac, c) {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Returns the feed for the annotator's address, connecting to it if it isn't
+// already
+func (a ReadsbAnnotator) Feed() *ReadsbFeed {
+	readsbFeedsLock.Lock()
+	defer readsbFeedsLock.Unlock()
+	if f, ok := readsbFeeds[a.Address]; ok {
+		return f
+	}
+	f := &ReadsbFeed{
+		Address:        a.Address,
+		Format:         strings.ToLower(a.Format),
+		MaxAge:         time.Duration(a.MaxAgeSeconds) * time.Second,
+		Aircraft:       map[string]*ReadsbAircraft{},
+		ByCallsign:     map[string]string{},
+		ByRegistration: map[string]string{},
+	}
+	if f.Format == "" {
+		f.Format = readsbFormatSBS
+	}
+	if f.MaxAge <= 0 {
+		f.MaxAge = readsbDefaultMaxAge
+	}
+	readsbFeeds[a.Address] = f
+	go f.Run()
+	return f
+}
+
+// Connects to every configured feed so aircraft are known before the first
+// message comes in
+func StartReadsbFeeds() {
+	for _, step := range config.Steps {
+		if r := step.Annotate.Readsb; r.Configured() {
+			r.Feed()
+		}
+	}
+}
+
+// Reads from the feed forever, reconnecting if the connection drops
+func (f *ReadsbFeed) Run() {
+	for {
+		log.Info(Content("connecting to %s feed at %s", f.Format, f.Address))
+		if err := f.Read(); err != nil {
+			log.Warn(Attention("%s feed at %s: %s, reconnecting in %s", f.Format, f.Address, err, readsbReconnectDelay))
+		}
+		time.Sleep(readsbReconnectDelay)
+	}
+}
+
+func (f *ReadsbFeed) Read() error {
+	conn, err := net.DialTimeout("tcp", f.Address, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	log.Info(Success("connected to %s feed at %s", f.Format, f.Address))
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lastExpiry := time.Now()
+	for scanner.Scan() {
+		now := time.Now()
+		switch f.Format {
+		case readsbFormatJSON:
+			f.UpdateJSON(scanner.Bytes(), now)
+		default:
+			f.UpdateSBS(scanner.Text(), now)
+		}
+		if now.Sub(lastExpiry) > time.Minute {
+			f.Expire(now)
+			lastExpiry = now
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection closed")
+}
+
+// Returns the aircraft with hex, adding it if it's new. The lock must be held.
+func (f *ReadsbFeed) aircraft(hex string) *ReadsbAircraft {
+	ac, ok := f.Aircraft[hex]
+	if !ok {
+		ac = &ReadsbAircraft{ICAOHex: hex}
+		f.Aircraft[hex] = ac
+	}
+	return ac
+}
+
+// Updates an aircraft from a BaseStation line like
+// MSG,3,1,1,A0B1C2,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,,35000,,,40.1,-75.2,,,0,0,0,0
+func (f *ReadsbFeed) UpdateSBS(line string, now time.Time) {
+	fields := strings.Split(strings.TrimSpace(line), ",")
+	if len(fields) < 22 || fields[0] != "MSG" || len(fields[4]) != 6 {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	ac := f.aircraft(strings.ToUpper(fields[4]))
+	ac.lastSeen = now
+	if cs := strings.TrimSpace(fields[10]); cs != "" {
+		ac.Callsign = strings.ToUpper(cs)
+		f.ByCallsign[ac.Callsign] = ac.ICAOHex
+	}
+	if v, err := strconv.ParseInt(fields[11], 10, 64); err == nil {
+		ac.BarometricAltitudeFeet = v
+	}
+	if v, err := strconv.ParseFloat(fields[12], 64); err == nil {
+		ac.GroundSpeedKnots = v
+	}
+	if v, err := strconv.ParseFloat(fields[13], 64); err == nil {
+		ac.TrackDegrees = v
+	}
+	lat, laterr := strconv.ParseFloat(fields[14], 64)
+	lon, lonerr := strconv.ParseFloat(fields[15], 64)
+	if laterr == nil && lonerr == nil {
+		ac.Latitude, ac.Longitude, ac.lastPosition = lat, lon, now
+	}
+	if v, err := strconv.ParseInt(fields[16], 10, 64); err == nil {
+		ac.VerticalRateFeetMinute = v
+	}
+	if fields[17] != "" {
+		ac.Squawk = fields[17]
+	}
+	// Flags are -1 (or 1) for true and 0 for false
+	if fields[19] != "" {
+		ac.Emergency = fields[19] != "0"
+	}
+	if fields[21] != "" {
+		ac.OnGround = fields[21] != "0"
+	}
+}
+
+// Updates an aircraft from a line of readsb's JSON output, which has the same
+// fields as aircraft.json
+func (f *ReadsbFeed) UpdateJSON(line []byte, now time.Time) {
+	var j struct {
+		Hex          string   `json:"hex"`
+		Flight       string   `json:"flight"`
+		Registration string   `json:"r"`
+		AltBaro      any      `json:"alt_baro"`
+		GroundSpeed  *float64 `json:"gs"`
+		Track        *float64 `json:"track"`
+		BaroRate     *float64 `json:"baro_rate"`
+		Squawk       string   `json:"squawk"`
+		Emergency    string   `json:"emergency"`
+		Lat          *float64 `json:"lat"`
+		Lon          *float64 `json:"lon"`
+		SeenPos      float64  `json:"seen_pos"`
+	}
+	if err := json.Unmarshal(line, &j); err != nil || j.Hex == "" {
+		return
+	}
+	f.Lock()
+	defer f.Unlock()
+	ac := f.aircraft(strings.ToUpper(strings.TrimPrefix(j.Hex, "~")))
+	ac.lastSeen = now
+	if cs := strings.ToUpper(strings.TrimSpace(j.Flight)); cs != "" {
+		ac.Callsign = cs
+		f.ByCallsign[cs] = ac.ICAOHex
+	}
+	if j.Registration != "" {
+		ac.Registration = j.Registration
+		f.ByRegistration[NormalizeAircraftRegistration(j.Registration)] = ac.ICAOHex
+	}
+	switch alt := j.AltBaro.(type) {
+	case float64:
+		ac.BarometricAltitudeFeet, ac.OnGround = int64(alt), false
+	case string:
+		ac.OnGround = alt == "ground"
+	}
+	if j.GroundSpeed != nil {
+		ac.GroundSpeedKnots = *j.GroundSpeed
+	}
+	if j.Track != nil {
+		ac.TrackDegrees = *j.Track
+	}
+	if j.BaroRate != nil {
+		ac.VerticalRateFeetMinute = int64(*j.BaroRate)
+	}
+	if j.Squawk != "" {
+		ac.Squawk = j.Squawk
+	}
+	if j.Emergency != "" {
+		ac.Emergency = j.Emergency != "none"
+	}
+	if j.Lat != nil && j.Lon != nil {
+		ac.Latitude, ac.Longitude = *j.Lat, *j.Lon
+		ac.lastPosition = now.Add(-time.Duration(j.SeenPos * float64(time.Second)))
+	}
+}
+
+// Forgets aircraft that haven't been heard from in MaxAge
+func (f *ReadsbFeed) Expire(now time.Time) {
+	f.Lock()
+	defer f.Unlock()
+	for hex, ac := range f.Aircraft {
+		if now.Sub(ac.lastSeen) > f.MaxAge {
+			delete(f.Aircraft, hex)
+		}
+	}
+	for cs, hex := range f.ByCallsign {
+		if ac, ok := f.Aircraft[hex]; !ok || ac.Callsign != cs {
+			delete(f.ByCallsign, cs)
+		}
+	}
+	for reg, hex := range f.ByRegistration {
+		if _, ok := f.Aircraft[hex]; !ok {
+			delete(f.ByRegistration, reg)
+		}
+	}
+}
+
+// Finds an aircraft by ICAO hex, then registration, then callsign, returning
+// a copy
+func (f *ReadsbFeed) Find(reg, hex, callsign string) (ac ReadsbAircraft, matchedBy string, ok bool) {
+	f.RLock()
+	defer f.RUnlock()
+	candidates := [][2]string{
+		{strings.ToUpper(hex), "ICAOHex"},
+		{f.ByRegistration[NormalizeAircraftRegistration(reg)], "Registration"},
+		{f.ByCallsign[strings.ToUpper(callsign)], "Callsign"},
+	}
+	for _, c := range candidates {
+		if a, found := f.Aircraft[c[0]]; found && c[0] != "" && time.Since(a.lastSeen) <= f.MaxAge {
+			return *a, c[1], true
+		}
+	}
+	return ac, "", false
+}
+
+func (a ReadsbAnnotator) Annotate(m APMessage) (APMessage, error) {
+	ref, err := ReferenceLocationFor(m, a.ReferenceGeolocation)
+	if err != nil {
+		return m, err
+	}
+
+	hex := GetAPMessageCommonFieldAsString(m, "ICAOHex")
+	if hex == "" {
+		// ACARS messages don't have an address, but the aircraft database
+		// might know it
+		hex, _ = m["AircraftDatabase.ICAOHex"].(string)
+	}
+	ac, matchedBy, ok := a.Feed().Find(GetAPMessageCommonFieldAsString(m, "TailCode"), hex, ADSBCallsign(m))
+	if !ok {
+		log.Debug(Aside("%s: aircraft not found", a.Name()))
+		return m, nil
+	}
+	now := time.Now()
+	ac.SecondsSinceLastMessage = now.Sub(ac.lastSeen).Seconds()
+	ac.MatchedBy = matchedBy
+	apm := FormatAsAPMessage(ac, a.Name())
+	if !ac.lastPosition.IsZero() {
+		rel, err := ref.PositionOf(ac.Latitude, ac.Longitude, float64(ac.BarometricAltitudeFeet))
+		if err != nil {
+			return m, err
+		}
+		c := ReadsbCalculated{
+			AircraftLatitude:              ac.Latitude,
+			AircraftLongitude:             ac.Longitude,
+			AircraftBarometerAltitudeFeet: ac.BarometricAltitudeFeet,
+			AircraftGeolocation:           fmt.Sprintf("%f,%f", ac.Latitude, ac.Longitude),
+			AircraftDistanceKm:            rel.DistanceKm,
+			AircraftDistanceMi:            rel.DistanceMi,
+			AircraftBearingDegrees:        rel.BearingDegrees,
+			AircraftElevationAngleDegrees: rel.ElevationAngleDegrees,
+			ReferenceStation:              ref.Name,
+			PositionAgeSeconds:            now.Sub(ac.lastPosition).Seconds(),
+		}
+		apm = MergeAPMessages(apm, FormatAsAPMessage(c, a.Name()))
+	}
+	// Remove all but any selected fields
+	if len(a.SelectedFields) > 0 {
+		for field := range apm {
+			if !slices.Contains(a.SelectedFields, field) {
+				delete(apm, field)
+			}
+		}
+	}
+	return MergeAPMessages(m, apm), nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a BaseStation line with the fields set and the rest empty
+func sbsLine(msgType string, hex string, fields map[int]string) string {
+	line := make([]string, 22)
+	line[0], line[1], line[4] = "MSG", msgType, hex
+	for i, v := range fields {
+		line[i] = v
+	}
+	return strings.Join(line, ",")
+}
+
+func newTestReadsbFeed() *ReadsbFeed {
+	return &ReadsbFeed{
+		MaxAge:         time.Minute,
+		Aircraft:       map[string]*ReadsbAircraft{},
+		ByCallsign:     map[string]string{},
+		ByRegistration: map[string]string{},
+	}
+}
+
+func TestReadsbUpdateSBS(t *testing.T) {
+	f := newTestReadsbFeed()
+	now := time.Now()
+	lines := []string{
+		// Identification
+		sbsLine("1", "a0b1c2", map[int]string{10: "UAL123  "}),
+		// Airborne position
+		sbsLine("3", "A0B1C2", map[int]string{11: "35000", 14: "40.1", 15: "-75.2", 19: "0", 21: "0"}),
+		// Airborne velocity
+		sbsLine("4", "A0B1C2", map[int]string{12: "450.5", 13: "271.3", 16: "-640"}),
+		// Surveillance ID with an emergency squawk
+		sbsLine("6", "A0B1C2", map[int]string{17: "7700", 19: "-1"}),
+		// Not a MSG line, too short, and a bad address
+		"STA,,1,1,A0B1C2,1,2024/01/01,12:00:00.000,2024/01/01,12:00:00.000,RM",
+		"MSG,3,1,1,A0B1C2",
+		sbsLine("3", "A0B1", map[int]string{14: "1", 15: "1"}),
+	}
+	for _, l := range lines {
+		f.UpdateSBS(l, now)
+	}
+	if len(f.Aircraft) != 1 {
+		t.Fatalf("%d aircraft, want 1", len(f.Aircraft))
+	}
+	got := *f.Aircraft["A0B1C2"]
+	want := ReadsbAircraft{
+		ICAOHex:                "A0B1C2",
+		Callsign:               "UAL123",
+		Latitude:               40.1,
+		Longitude:              -75.2,
+		BarometricAltitudeFeet: 35000,
+		GroundSpeedKnots:       450.5,
+		TrackDegrees:           271.3,
+		VerticalRateFeetMinute: -640,
+		Squawk:                 "7700",
+		Emergency:              true,
+		lastSeen:               now,
+		lastPosition:           now,
+	}
+	if got != want {
+		t.Errorf("aircraft %+v\nwant     %+v", got, want)
+	}
+	if f.ByCallsign["UAL123"] != "A0B1C2" {
+		t.Errorf("callsign UAL123 maps to %q", f.ByCallsign["UAL123"])
+	}
+}
+
+func TestReadsbUpdateJSON(t *testing.T) {
+	f := newTestReadsbFeed()
+	now := time.Now()
+	f.UpdateJSON([]byte(`{"hex":"~a0b1c2","flight":"UAL123 ","r":"N123AB","alt_baro":35000,"gs":450.5,"track":271.3,"baro_rate":-640,"squawk":"1200","emergency":"none","lat":40.1,"lon":-75.2,"seen_pos":2.5}`), now)
+	got := *f.Aircraft["A0B1C2"]
+	want := ReadsbAircraft{
+		ICAOHex:                "A0B1C2",
+		Callsign:               "UAL123",
+		Registration:           "N123AB",
+		Latitude:               40.1,
+		Longitude:              -75.2,
+		BarometricAltitudeFeet: 35000,
+		GroundSpeedKnots:       450.5,
+		TrackDegrees:           271.3,
+		VerticalRateFeetMinute: -640,
+		Squawk:                 "1200",
+		lastSeen:               now,
+		lastPosition:           now.Add(-2500 * time.Millisecond),
+	}
+	if got != want {
+		t.Errorf("aircraft %+v\nwant     %+v", got, want)
+	}
+
+	// On the ground, and no position in this update
+	f.UpdateJSON([]byte(`{"hex":"a0b1c2","alt_baro":"ground"}`), now.Add(time.Second))
+	got = *f.Aircraft["A0B1C2"]
+	if !got.OnGround || got.BarometricAltitudeFeet != 35000 || got.Latitude != 40.1 {
+		t.Errorf("after ground update %+v, want on the ground with the last altitude and position", got)
+	}
+
+	// Without an address, or not JSON
+	f.UpdateJSON([]byte(`{"flight":"DAL1"}`), now)
+	f.UpdateJSON([]byte(`MSG,3`), now)
+	if len(f.Aircraft) != 1 {
+		t.Errorf("%d aircraft, want 1", len(f.Aircraft))
+	}
+}
+
+func TestReadsbFindAndExpire(t *testing.T) {
+	f := newTestReadsbFeed()
+	now := time.Now()
+	f.UpdateJSON([]byte(`{"hex":"a0b1c2","flight":"UAL123","r":"N123AB"}`), now)
+	f.UpdateJSON([]byte(`{"hex":"c2b1a0","flight":"DAL456"}`), now.Add(-2*time.Minute))
+	tests := []struct {
+		name               string
+		reg, hex, callsign string
+		wantHex, matchedBy string
+	}{
+		{name: "hex", hex: "a0b1c2", wantHex: "A0B1C2", matchedBy: "ICAOHex"},
+		{name: "registration", reg: "N-123AB", wantHex: "A0B1C2", matchedBy: "Registration"},
+		{name: "callsign", callsign: "ual123", wantHex: "A0B1C2", matchedBy: "Callsign"},
+		{name: "hex before callsign", hex: "A0B1C2", callsign: "DAL456", wantHex: "A0B1C2", matchedBy: "ICAOHex"},
+		{name: "too old", callsign: "DAL456"},
+		{name: "unknown", hex: "FFFFFF", reg: "N999ZZ", callsign: "SWA1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, matchedBy, ok := f.Find(tt.reg, tt.hex, tt.callsign)
+			if ok != (tt.wantHex != "") || ac.ICAOHex != tt.wantHex || matchedBy != tt.matchedBy {
+				t.Errorf("found %q by %q (%v), want %q by %q", ac.ICAOHex, matchedBy, ok, tt.wantHex, tt.matchedBy)
+			}
+		})
+	}
+
+	f.Expire(now)
+	if _, ok := f.Aircraft["C2B1A0"]; ok {
+		t.Error("old aircraft wasn't expired")
+	}
+	if _, ok := f.ByCallsign["DAL456"]; ok {
+		t.Error("old aircraft's callsign wasn't expired")
+	}
+	if _, ok := f.Aircraft["A0B1C2"]; !ok || f.ByRegistration["n123ab"] != "A0B1C2" {
+		t.Error("current aircraft was expired")
+	}
+}
+
+func TestReadsbAnnotateKeepsEarlierPosition(t *testing.T) {
+	a := ReadsbAnnotator{Address: "readsb-annotate-test:30003", ReferenceGeolocation: "40,-75"}
+	f := newTestReadsbFeed()
+	f.Address = a.Address
+	readsbFeedsLock.Lock()
+	readsbFeeds[a.Address] = f
+	readsbFeedsLock.Unlock()
+	now := time.Now()
+	f.UpdateSBS(sbsLine("1", "A0B1C2", map[int]string{10: "UAL123"}), now)
+
+	// From tar1090, earlier in the step
+	m := APMessage{
+		ACARSProcessorPrefix + "FlightNumber":      "UAL123",
+		ACARSProcessorPrefix + "AircraftLatitude":  40.5,
+		ACARSProcessorPrefix + "AircraftLongitude": -74.5,
+	}
+	got, err := a.Annotate(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["ReadsbAnnotator.MatchedBy"] != "Callsign" {
+		t.Errorf("matched by %v, want Callsign", got["ReadsbAnnotator.MatchedBy"])
+	}
+	if lat := GetAPMessageCommonFieldAsFloat64(got, "AircraftLatitude"); lat != 40.5 {
+		t.Errorf("latitude %v, want the earlier 40.5", lat)
+	}
+
+	// Once the feed has a position it's used
+	f.UpdateSBS(sbsLine("3", "A0B1C2", map[int]string{11: "12000", 14: "40.1", 15: "-75.2"}), now)
+	got, err = a.Annotate(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lat := GetAPMessageCommonFieldAsFloat64(got, "AircraftLatitude"); lat != 40.1 {
+		t.Errorf("latitude %v, want 40.1", lat)
+	}
+	if alt, _ := AircraftAltitudeFeet(got); alt != 12000 {
+		t.Errorf("altitude %v, want 12000", alt)
+	}
+	if km := GetAPMessageCommonFieldAsFloat64(got, "AircraftDistanceKm"); km == 0 {
+		t.Error("distance wasn't calculated")
+	}
+}
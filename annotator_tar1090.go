@@ -4,25 +4,51 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"reflect"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/jftuga/geodist"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// How long to reuse aircraft.json if CacheTTLSeconds isn't set
+	tar1090DefaultCacheTTL = 5 * time.Second
+	// How old messages are before they use historical positions if
+	// HistoricalAfterSeconds isn't set
+	tar1090DefaultHistoricalAfter = 60 * time.Second
+	// How often to download aircraft.json if PollIntervalSeconds isn't set
+	tar1090DefaultPollInterval = 10 * time.Second
+)
+
+var (
+	// The latest aircraft from each polled tar1090 instance, keyed by URL
+	tar1090Snapshots     = map[string]*Tar1090Snapshot{}
+	tar1090SnapshotsLock sync.RWMutex
+)
+
 type Tar1090Annotator struct {
 	Annotator
 	Module
 	// URL to your tar1090 instance
 	URL string `jsonschema:"required,example:http://tar1090/" default:"http://tar1090/"`
-	// Geolocation to use for distance calculations (LAT,LON).
+	// Geolocation to use for distance calculations (LAT,LON) if the station isn't in Stations.
 	ReferenceGeolocation string `default:"35.6244416,139.7753782"`
+	// Download aircraft.json in the background this often instead of when messages come in (-1 to disable).
+	PollIntervalSeconds int64 `jsonschema:"default=10" default:"10"`
+	// Look up where the aircraft was when older messages were sent (like ones queued in the database) from tar1090's trace files, rather than where it is now.
+	HistoricalPositions bool `jsonschema:"default=false" default:"false"`
+	// Messages sent more than this many seconds ago use historical positions.
+	HistoricalAfterSeconds int64 `jsonschema:"default=60" default:"60"`
+	// Reuse aircraft.json for this many seconds, so messages close together share one download.
+	CacheTTLSeconds int64 `jsonschema:"default=5" default:"5"`
+	// Most requests to make per minute (0 for no limit). Lookups over the limit are skipped.
+	RequestsPerMinute float64 `jsonschema:"default=0" default:"0"`
+	// How many requests can be made at once before RequestsPerMinute applies.
+	RequestBurst int `jsonschema:"default=1" default:"1"`
 	// Only provide these fields to future steps.
 	SelectedFields []string
 }
@@ -62,6 +88,7 @@ type TJSONAircraft struct {
 	AircraftOwnerOperator      string   `json:"ownOp,omitempty"`
 	AircraftManufactureYear    string   `json:"year,omitempty"`
 	AltimeterBarometerFeet     int64    `json:"alt_baro,omitempty" ap:"AircraftBarometerAltitudeFeet"`
+	OnGround                   bool     `json:"-"` // alt_baro is "ground"
 	AltimeterBarometerRateFeet float64  `json:"baro_rate,omitempty" ap:"AircraftBarometerAltitudeRateFeet"`
 	Squawk                     string   `json:"squawk,omitempty"`
 	Emergency                  string   `json:"emergency,omitempty"`
@@ -100,12 +127,58 @@ type TJSONAircraft struct {
 	RSSISignalPowerdBm float64 `json:"rssi,omitempty"`
 }
 
+// Decodes alt_baro, which is either a number or "ground"
+func (a *TJSONAircraft) UnmarshalJSON(b []byte) error {
+	type aircraft TJSONAircraft
+	j := struct {
+		*aircraft
+		AltBaro any `json:"alt_baro"`
+	}{aircraft: (*aircraft)(a)}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	switch alt := j.AltBaro.(type) {
+	case float64:
+		a.AltimeterBarometerFeet, a.OnGround = int64(alt), false
+	case string:
+		a.AltimeterBarometerFeet, a.OnGround = 0, alt == "ground"
+	}
+	return nil
+}
+
 type TAR1090Calculated struct {
 	AircraftGeolocation          string  `ap:"AircraftGeolocation"`
 	AircraftGeolocationLatitude  float64 `ap:"AircraftLatitude"`
 	AircraftGeolocationLongitude float64 `ap:"AircraftLongitude"`
 	AircraftDistanceKm           float64 `ap:"AircraftDistanceKm"`
 	AircraftDistanceMi           float64 `ap:"AircraftDistanceMi"`
+	// Degrees from true north, from the station or reference geolocation
+	AircraftBearingDegrees float64 `ap:"AircraftBearingDegrees"`
+	// Degrees above the horizon, from the station or reference geolocation
+	AircraftElevationAngleDegrees float64 `ap:"AircraftElevationAngleDegrees"`
+	// Name of the station distances are from, if it's in Stations
+	ReferenceStation string
+	// Which of ICAOHex, Registration or Callsign found the aircraft
+	MatchedBy string
+	// How old the position was when the message was annotated or, for
+	// positions from a trace, how far the message was from the nearest point
+	PositionAgeSeconds float64
+	// Live if the position is from aircraft.json, or Trace if it's where the
+	// aircraft was when the message was sent
+	PositionSource string
+}
+
+// The callsign the aircraft likely broadcasts over ADS-B for the message's
+// flight, like UAL123 for UA0123
+func ADSBCallsign(m APMessage) string {
+	if f := GetAPMessageCommonFieldAsString(m, "FlightNumberICAO"); f != "" {
+		return f
+	}
+	flight := strings.ToUpper(strings.TrimSpace(GetAPMessageCommonFieldAsString(m, "FlightNumber")))
+	if r, ok := Airlines.ParseFlightNumber(flight); ok && r.FlightNumberICAO != "" {
+		return r.FlightNumberICAO
+	}
+	return flight
 }
 
 func NormalizeAircraftRegistration(reg string) string {
@@ -120,85 +193,207 @@ func NormalizeAircraftRegistration(reg string) string {
 	return strings.ToLower(reg)
 }
 
-// Wrapper around the SingleAircraftQueryByRegistration API
-func (a Tar1090Annotator) SingleAircraftQueryByRegistration(reg string) (aircraft TJSONAircraft, err error) {
-	reg = NormalizeAircraftRegistration(reg)
+// An aircraft.json download, indexed so finding an aircraft is a map lookup
+type Tar1090Snapshot struct {
+	// When tar1090 wrote aircraft.json
+	Now            time.Time
+	ByRegistration map[string]TJSONAircraft
+	ByHex          map[string]TJSONAircraft
+	ByFlight       map[string]TJSONAircraft
+}
+
+func NewTar1090Snapshot(tjson Tar1090AircraftJSON) *Tar1090Snapshot {
+	s := &Tar1090Snapshot{
+		Now:            time.UnixMilli(int64(tjson.Now * 1000)),
+		ByRegistration: map[string]TJSONAircraft{},
+		ByHex:          map[string]TJSONAircraft{},
+		ByFlight:       map[string]TJSONAircraft{},
+	}
+	for _, aircraft := range tjson.Aircraft {
+		if aircraft.Hex != "" {
+			s.ByHex[strings.ToUpper(aircraft.Hex)] = aircraft
+		}
+		if aircraft.Registration != "" {
+			s.ByRegistration[NormalizeAircraftRegistration(aircraft.Registration)] = aircraft
+		}
+		if flight := strings.ToUpper(strings.TrimSpace(aircraft.AircraftTailCode)); flight != "" {
+			s.ByFlight[flight] = aircraft
+		}
+	}
+	return s
+}
+
+// Finds an aircraft by ICAO hex, then registration (which tar1090 doesn't
+// always have), then callsign
+func (s *Tar1090Snapshot) Find(reg, hex, flight string) (aircraft TJSONAircraft, matchedBy string, ok bool) {
+	if aircraft, ok = s.ByHex[strings.ToUpper(hex)]; ok && hex != "" {
+		return aircraft, "ICAOHex", true
+	}
+	if aircraft, ok = s.ByRegistration[NormalizeAircraftRegistration(reg)]; ok && reg != "" {
+		return aircraft, "Registration", true
+	}
+	if aircraft, ok = s.ByFlight[strings.ToUpper(flight)]; ok && flight != "" {
+		return aircraft, "Callsign", true
+	}
+	return TJSONAircraft{}, "", false
+}
+
+// Downloads aircraft.json, reusing a cached copy younger than ttl
+func (a Tar1090Annotator) FetchSnapshot(ttl time.Duration) (*Tar1090Snapshot, error) {
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/data/aircraft.json?_=%d/", a.URL, time.Now().Unix()), nil)
 	if err != nil {
-		return aircraft, err
+		return nil, err
 	}
-	client := &http.Client{}
 
 	log.Debug(Aside("%s: making call to tar1090", a.Name()))
-	resp, err := client.Do(req)
+	body, err := CachedHTTPRequest(req, HTTPCacheOptions{
+		// Leave out the cache buster
+		Key:     a.URL + "/data/aircraft.json",
+		TTL:     ttl,
+		Limiter: RateLimiter(a.Name()+a.URL, a.RequestsPerMinute, a.RequestBurst),
+	})
 	if err != nil {
-		return aircraft, err
+		return nil, err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
 	tjson := Tar1090AircraftJSON{}
-	err = json.Unmarshal(body, &tjson)
-	if err != nil {
-		return aircraft, err
+	if err := json.Unmarshal(body, &tjson); err != nil {
+		return nil, err
+	}
+	if tjson.Now == 0 {
+		return nil, errors.New("unable to parse returned aircraft positions")
 	}
+	return NewTar1090Snapshot(tjson), nil
+}
 
-	if (&tjson != &Tar1090AircraftJSON{}) {
-		for _, aircraft := range tjson.Aircraft {
-			// Strip spaces and periods
-			if NormalizeAircraftRegistration(aircraft.Registration) == reg {
-				return aircraft, nil
-			}
+// Returns the latest snapshot from the poller if there is one, otherwise
+// downloads one
+func (a Tar1090Annotator) Snapshot() (*Tar1090Snapshot, error) {
+	if a.PollInterval() > 0 {
+		tar1090SnapshotsLock.RLock()
+		s, ok := tar1090Snapshots[a.URL]
+		tar1090SnapshotsLock.RUnlock()
+		if ok {
+			return s, nil
 		}
-		log.Debug(Aside("%s: aircraft not found", a.Name()))
-		return aircraft, nil
-	} else {
-		return aircraft, errors.New("unable to parse returned aircraft position")
 	}
+	ttl := time.Duration(a.CacheTTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = tar1090DefaultCacheTTL
+	}
+	return a.FetchSnapshot(ttl)
 }
 
-func (a Tar1090Annotator) Annotate(m APMessage) (APMessage, error) {
-	if a.ReferenceGeolocation == "" {
-		log.Info(Note("%s enabled but geolocation not set, using '0,0'", a.Name()))
-		a.ReferenceGeolocation = "0,0"
+// Returns how often to download aircraft.json in the background, or zero if
+// polling is turned off
+func (a Tar1090Annotator) PollInterval() time.Duration {
+	switch {
+	case a.PollIntervalSeconds < 0:
+		return 0
+	case a.PollIntervalSeconds == 0:
+		return tar1090DefaultPollInterval
 	}
-	coords := strings.Split(a.ReferenceGeolocation, ",")
-	if len(coords) != 2 {
-		return m, fmt.Errorf("geolocation coordinates are not in the format 'LAT,LON'")
+	return time.Duration(a.PollIntervalSeconds) * time.Second
+}
+
+// Refreshes the snapshot for a.URL every PollInterval, forever
+func (a Tar1090Annotator) Poll() {
+	interval := a.PollInterval()
+	for {
+		s, err := a.FetchSnapshot(0)
+		if err != nil {
+			log.Warn(Attention("%s: unable to refresh aircraft from %s: %s", a.Name(), a.URL, err))
+		} else {
+			tar1090SnapshotsLock.Lock()
+			tar1090Snapshots[a.URL] = s
+			tar1090SnapshotsLock.Unlock()
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Starts a poller for each tar1090 instance that's configured to be polled
+func StartTar1090Pollers() {
+	started := map[string]bool{}
+	for _, step := range config.Steps {
+		t := step.Annotate.Tar1090
+		if !t.Configured() || t.PollInterval() <= 0 || started[t.URL] {
+			continue
+		}
+		started[t.URL] = true
+		log.Info(Content("%s: polling %s every %s", t.Name(), t.URL, t.PollInterval()))
+		go t.Poll()
+	}
+}
+
+func (a Tar1090Annotator) Annotate(m APMessage) (APMessage, error) {
+	ref, err := ReferenceLocationFor(m, a.ReferenceGeolocation)
+	if err != nil {
+		return m, err
 	}
-	olat, _ := strconv.ParseFloat(coords[0], 64)
-	olon, _ := strconv.ParseFloat(coords[1], 64)
-	origin := geodist.Coord{Lat: olat, Lon: olon}
 
-	aircraftInfo := TJSONAircraft{}
-	var err error
 	tailcode := GetAPMessageCommonFieldAsString(m, "TailCode")
-	if tailcode == "" {
-		log.Debug(Aside("%s: did not find a tail code in message, this is not unusual", a.Name()))
+	hex := GetAPMessageCommonFieldAsString(m, "ICAOHex")
+	flight := ADSBCallsign(m)
+	if tailcode == "" && hex == "" && flight == "" {
+		log.Debug(Aside("%s: did not find a tail code, ICAO hex or flight number in message, this is not unusual", a.Name()))
 		return m, nil
 	}
-	aircraftInfo, err = a.SingleAircraftQueryByRegistration(tailcode)
+	snapshot, err := a.Snapshot()
 	if err != nil {
 		return m, fmt.Errorf("error finding aircraft position from tar1090: %v", err)
 	}
-
-	aircraft := geodist.Coord{Lat: aircraftInfo.Latitude, Lon: aircraftInfo.Longitude}
-	var mi,km float64
-	if aircraft.Lat == 0.0 && aircraft.Lon == 0.0 {
-		// 0 distance but 0,0 geolocation should indicate geolocation failed.
-		mi, km = 0,0
-	} else {
-		mi, km, err = geodist.VincentyDistance(origin, aircraft)
-		if err != nil {
-			return m, fmt.Errorf("error calculating distance: %s", err)
+	aircraftInfo, matchedBy, ok := snapshot.Find(tailcode, hex, flight)
+	positionAge := aircraftInfo.SeenPosition + time.Since(snapshot.Now).Seconds()
+	positionSource := "Live"
+	// Messages from a while ago (like a backlog after a restart) should get
+	// where the aircraft was then, not where it is now
+	sent := GetAPMessageCommonFieldAsInt64(m, "UnixTimestamp")
+	if ok {
+		hex = aircraftInfo.Hex
+	}
+	historicalAfter := time.Duration(a.HistoricalAfterSeconds) * time.Second
+	if historicalAfter <= 0 {
+		historicalAfter = tar1090DefaultHistoricalAfter
+	}
+	if a.HistoricalPositions && sent > 0 && hex != "" && time.Since(time.Unix(sent, 0)) > historicalAfter {
+		if p, found := a.TraceAt(hex, time.Unix(sent, 0)); found {
+			if !ok {
+				aircraftInfo, matchedBy, ok = TJSONAircraft{Hex: strings.ToLower(hex)}, "ICAOHex", true
+			}
+			aircraftInfo.Latitude, aircraftInfo.Longitude = p.Latitude, p.Longitude
+			aircraftInfo.AltimeterBarometerFeet, aircraftInfo.OnGround = p.AltitudeFeet, p.OnGround
+			// The trace only has barometric altitude
+			aircraftInfo.AltimeterGeometricFeet = 0
+			positionAge, positionSource = p.AgeSeconds, "Trace"
 		}
 	}
+	if !ok {
+		log.Debug(Aside("%s: aircraft not found", a.Name()))
+		return m, nil
+	}
+
+	// Prefer geometric altitude for elevation angles
+	altitude := aircraftInfo.AltimeterGeometricFeet
+	if altitude == 0 {
+		altitude = float64(aircraftInfo.AltimeterBarometerFeet)
+	}
+	rel, err := ref.PositionOf(aircraftInfo.Latitude, aircraftInfo.Longitude, altitude)
+	if err != nil {
+		return m, err
+	}
 
 	c := TAR1090Calculated{
-		AircraftGeolocation:          fmt.Sprintf("%f,%f", aircraftInfo.Latitude, aircraftInfo.Longitude),
-		AircraftGeolocationLatitude:  aircraft.Lat,
-		AircraftGeolocationLongitude: aircraft.Lon,
-		AircraftDistanceKm:           km,
-		AircraftDistanceMi:           mi,
+		AircraftGeolocation:           fmt.Sprintf("%f,%f", aircraftInfo.Latitude, aircraftInfo.Longitude),
+		AircraftGeolocationLatitude:   aircraftInfo.Latitude,
+		AircraftGeolocationLongitude:  aircraftInfo.Longitude,
+		AircraftDistanceKm:            rel.DistanceKm,
+		AircraftDistanceMi:            rel.DistanceMi,
+		AircraftBearingDegrees:        rel.BearingDegrees,
+		AircraftElevationAngleDegrees: rel.ElevationAngleDegrees,
+		ReferenceStation:              ref.Name,
+		MatchedBy:                     matchedBy,
+		PositionAgeSeconds:            positionAge,
+		PositionSource:                positionSource,
 	}
 	// Create AP Messages from the API response and the calculated type above
 	// then merge them.
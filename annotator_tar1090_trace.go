@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Trace files change slowly, and backlogs have many messages from the
+	// same aircraft
+	tar1090TraceCacheTTL = 5 * time.Minute
+	// Don't guess where an aircraft was between points further apart than
+	// this, it probably wasn't being received
+	tar1090TraceMaxGapSeconds = 600
+)
+
+// readsb's trace_full_<hex>.json
+type Tar1090Trace struct {
+	ICAO      string  `json:"icao"`
+	Timestamp float64 `json:"timestamp"`
+	// Each point is [seconds after Timestamp, lat, lon, altitude or "ground",
+	// ground speed, track, flags, vertical rate, ...]
+	Trace [][]any `json:"trace"`
+}
+
+// A position from a trace, at or interpolated to a time
+type Tar1090TracePosition struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+	// Zero if on the ground or unknown
+	AltitudeFeet int64
+	OnGround     bool
+	// How far the time is from the nearest real point
+	AgeSeconds float64
+}
+
+type tar1090TracePoint struct {
+	t, lat, lon float64
+	alt         *float64
+	ground      bool
+}
+
+// Fetches the trace for hex that covers t, from the last day's trace or, if
+// it's older, globe_history
+func (a Tar1090Annotator) TraceAt(hex string, t time.Time) (p Tar1090TracePosition, ok bool) {
+	hex = strings.ToLower(strings.TrimPrefix(hex, "~"))
+	if len(hex) < 2 {
+		return p, false
+	}
+	paths := []string{
+		fmt.Sprintf("data/traces/%s/trace_full_%s.json", hex[len(hex)-2:], hex),
+		fmt.Sprintf("globe_history/%s/traces/%s/trace_full_%s.json", t.UTC().Format("2006/01/02"), hex[len(hex)-2:], hex),
+	}
+	for _, path := range paths {
+		trace, err := a.FetchTrace(path)
+		if err != nil {
+			log.Debug(Aside("%s: no trace at %s: %s", a.Name(), path, err))
+			continue
+		}
+		if p, ok = trace.PositionAt(t); ok {
+			return p, true
+		}
+	}
+	return p, false
+}
+
+func (a Tar1090Annotator) FetchTrace(path string) (trace Tar1090Trace, err error) {
+	url := fmt.Sprintf("%s/%s", strings.TrimSuffix(a.URL, "/"), path)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return trace, err
+	}
+	body, err := CachedHTTPRequest(req, HTTPCacheOptions{
+		Key:     url,
+		TTL:     tar1090TraceCacheTTL,
+		Limiter: RateLimiter(a.Name()+a.URL, a.RequestsPerMinute, a.RequestBurst),
+	})
+	if err != nil {
+		return trace, err
+	}
+	if err := json.Unmarshal(body, &trace); err != nil {
+		return trace, err
+	}
+	if trace.Timestamp == 0 {
+		return trace, errors.New("trace has no timestamp")
+	}
+	return trace, nil
+}
+
+func (tr Tar1090Trace) points() (points []tar1090TracePoint) {
+	for _, row := range tr.Trace {
+		if len(row) < 4 {
+			continue
+		}
+		offset, ok1 := row[0].(float64)
+		lat, ok2 := row[1].(float64)
+		lon, ok3 := row[2].(float64)
+		if !ok1 || !ok2 || !ok3 {
+			continue
+		}
+		p := tar1090TracePoint{t: tr.Timestamp + offset, lat: lat, lon: lon}
+		// "ground" and null don't have an altitude
+		if alt, ok := row[3].(float64); ok {
+			p.alt = &alt
+		}
+		p.ground = row[3] == "ground"
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].t < points[j].t })
+	return points
+}
+
+// Returns where the aircraft was at t, interpolating between the points
+// either side of it
+func (tr Tar1090Trace) PositionAt(t time.Time) (p Tar1090TracePosition, ok bool) {
+	points := tr.points()
+	target := float64(t.UnixMilli()) / 1000
+	i := sort.Search(len(points), func(i int) bool { return points[i].t >= target })
+	switch {
+	case len(points) == 0:
+		return p, false
+	// Before the trace starts or after it ends, use the closest point if
+	// it's close enough
+	case i == 0 || i == len(points):
+		if i == len(points) {
+			i--
+		}
+		age := math.Abs(points[i].t - target)
+		if age > tar1090TraceMaxGapSeconds {
+			return p, false
+		}
+		return points[i].position(t, age), true
+	}
+	before, after := points[i-1], points[i]
+	gap := after.t - before.t
+	if gap > tar1090TraceMaxGapSeconds {
+		// Use whichever end is close enough, if either is
+		if age := target - before.t; age <= tar1090TraceMaxGapSeconds/2 {
+			return before.position(t, age), true
+		}
+		if age := after.t - target; age <= tar1090TraceMaxGapSeconds/2 {
+			return after.position(t, age), true
+		}
+		return p, false
+	}
+	f := 0.0
+	if gap > 0 {
+		f = (target - before.t) / gap
+	}
+	dlon := after.lon - before.lon
+	// Go the short way across the antimeridian
+	if dlon > 180 {
+		dlon -= 360
+	} else if dlon < -180 {
+		dlon += 360
+	}
+	lon := before.lon + dlon*f
+	if lon > 180 {
+		lon -= 360
+	} else if lon < -180 {
+		lon += 360
+	}
+	p = Tar1090TracePosition{
+		Time:       t,
+		Latitude:   before.lat + (after.lat-before.lat)*f,
+		Longitude:  lon,
+		AgeSeconds: math.Min(target-before.t, after.t-target),
+		OnGround:   before.ground && after.ground,
+	}
+	if before.alt != nil && after.alt != nil {
+		p.AltitudeFeet = int64(math.Round(*before.alt + (*after.alt-*before.alt)*f))
+	}
+	return p, true
+}
+
+func (tp tar1090TracePoint) position(t time.Time, age float64) Tar1090TracePosition {
+	p := Tar1090TracePosition{Time: t, Latitude: tp.lat, Longitude: tp.lon, AgeSeconds: age, OnGround: tp.ground}
+	if tp.alt != nil {
+		p.AltitudeFeet = int64(*tp.alt)
+	}
+	return p
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTar1090TracePositionAt(t *testing.T) {
+	var trace Tar1090Trace
+	err := json.Unmarshal([]byte(`{"icao":"a0b1c2","timestamp":1700000000,"trace":[
+		[60,41.0,-74.0,12000,450.0,45.0,0,0],
+		[0,40.0,-75.0,10000,440.0,45.0,0,0],
+		[30],
+		[90,41.0,-74.0,null,450.0,45.0,0,0],
+		[200,50.0,179.5,35000,480.0,90.0,0,0],
+		[260,50.0,-179.5,35000,480.0,90.0,0,0],
+		[1500,51.0,-170.0,"ground",10.0,90.0,0,0],
+		[1510,51.0,-170.0,"ground",0.0,90.0,0,0]
+	]}`), &trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		offset int64
+		ok     bool
+		want   Tar1090TracePosition
+	}{
+		{name: "between points", offset: 30, ok: true, want: Tar1090TracePosition{Latitude: 40.5, Longitude: -74.5, AltitudeFeet: 11000, AgeSeconds: 30}},
+		{name: "on a point", offset: 60, ok: true, want: Tar1090TracePosition{Latitude: 41, Longitude: -74, AltitudeFeet: 12000}},
+		{name: "without altitude", offset: 75, ok: true, want: Tar1090TracePosition{Latitude: 41, Longitude: -74, AgeSeconds: 15}},
+		{name: "east of the antimeridian", offset: 215, ok: true, want: Tar1090TracePosition{Latitude: 50, Longitude: 179.75, AltitudeFeet: 35000, AgeSeconds: 15}},
+		{name: "west of the antimeridian", offset: 245, ok: true, want: Tar1090TracePosition{Latitude: 50, Longitude: -179.75, AltitudeFeet: 35000, AgeSeconds: 15}},
+		{name: "just after a gap starts", offset: 300, ok: true, want: Tar1090TracePosition{Latitude: 50, Longitude: -179.5, AltitudeFeet: 35000, AgeSeconds: 40}},
+		{name: "just before a gap ends", offset: 1400, ok: true, want: Tar1090TracePosition{Latitude: 51, Longitude: -170, OnGround: true, AgeSeconds: 100}},
+		{name: "middle of a gap", offset: 900},
+		{name: "on the ground", offset: 1505, ok: true, want: Tar1090TracePosition{Latitude: 51, Longitude: -170, OnGround: true, AgeSeconds: 5}},
+		{name: "shortly before the trace", offset: -100, ok: true, want: Tar1090TracePosition{Latitude: 40, Longitude: -75, AltitudeFeet: 10000, AgeSeconds: 100}},
+		{name: "long before the trace", offset: -700},
+		{name: "shortly after the trace", offset: 1710, ok: true, want: Tar1090TracePosition{Latitude: 51, Longitude: -170, OnGround: true, AgeSeconds: 200}},
+		{name: "long after the trace", offset: 2210},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := time.Unix(1700000000+tt.offset, 0)
+			got, ok := trace.PositionAt(at)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (position %+v)", ok, tt.ok, got)
+			}
+			if !ok {
+				return
+			}
+			tt.want.Time = at
+			if got != tt.want {
+				t.Errorf("position %+v\nwant     %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := (Tar1090Trace{Timestamp: 1700000000}).PositionAt(time.Unix(1700000000, 0)); ok {
+		t.Error("empty trace had a position")
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var WeatherPrefix = ACARSProcessorPrefix + "Weather"
+
+var (
+	// METAR KSFO 161856Z, SPECI KSFO 161856Z, TAF AMD KSFO 161720Z or just
+	// KSFO 161856Z
+	weatherReportRegex = regexp.MustCompile(`\b(?:(METAR|SPECI|TAF)\s+(?:(?:AMD|COR)\s+)?)?([A-Z]{4})\s+(\d{6})Z\b`)
+	// KSFO ATIS INFO B 1856Z or KSFO ARR ATIS B 1856Z
+	atisRegex = regexp.MustCompile(`\b([A-Z]{4})\s+(?:(?:ARR|DEP|ARRIVAL|DEPARTURE)\s+)?(?:D-)?ATIS\s+(?:INFO(?:RMATION)?\s+)?([A-Z])\s+(\d{4})Z\b`)
+	// TAF valid periods, like 1618/1724
+	tafValidityRegex = regexp.MustCompile(`^\d{4}/\d{4}$`)
+	// 28014G22KT, VRB03KT or 00000KT
+	windRegex = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(?:G(\d{2,3}))?(KT|MPS|KMH)$`)
+	// 10SM, 1/2SM, M1/4SM or P6SM
+	visibilityRegex = regexp.MustCompile(`^([MP])?(\d+(?:/\d+)?)SM$`)
+	// Meters, like 0800 or 9999
+	metricVisibilityRegex = regexp.MustCompile(`^(\d{4})(?:NDV)?$`)
+	// FEW008, BKN200CB or VV002
+	cloudRegex = regexp.MustCompile(`^(FEW|SCT|BKN|OVC|VV)(\d{3}|///)(CB|TCU)?$`)
+	// 17/11 or M02/M05
+	temperatureRegex = regexp.MustCompile(`^(M?\d{2})/(M?\d{2})?$`)
+	// A3002 or Q1013
+	altimeterRegex = regexp.MustCompile(`^([AQ])(\d{4})$`)
+	// -RA, +TSRA, VCSH or FZFG
+	phenomenaRegex = regexp.MustCompile(`^(-|\+|VC)?(MI|PR|BC|DR|BL|SH|TS|FZ)?((?:DZ|RA|SN|SG|IC|PL|GR|GS|UP|BR|FG|FU|VA|DU|SA|HZ|PY|PO|SQ|FC|SS|DS)*)$`)
+	// Where a TAF's first forecast ends and changes start
+	tafChangeRegex = regexp.MustCompile(`^(FM\d{6}|TEMPO|BECMG|PROB\d{2})$`)
+)
+
+// From best to worst
+var FlightCategories = []string{"VFR", "MVFR", "IFR", "LIFR"}
+
+var (
+	cloudCoverDescriptions = map[string]string{
+		"FEW": "few clouds",
+		"SCT": "scattered clouds",
+		"BKN": "broken clouds",
+		"OVC": "overcast",
+		"VV":  "vertical visibility",
+	}
+	phenomenaDescriptions = map[string]string{
+		"-": "light", "+": "heavy", "VC": "nearby",
+		"MI": "shallow", "PR": "partial", "BC": "patches of", "DR": "drifting",
+		"BL": "blowing", "SH": "showers of", "TS": "thunderstorms with",
+		"FZ": "freezing",
+		"DZ": "drizzle", "RA": "rain", "SN": "snow", "SG": "snow grains",
+		"IC": "ice crystals", "PL": "ice pellets", "GR": "hail",
+		"GS": "small hail", "UP": "unknown precipitation", "BR": "mist",
+		"FG": "fog", "FU": "smoke", "VA": "volcanic ash", "DU": "dust",
+		"SA": "sand", "HZ": "haze", "PY": "spray", "PO": "dust whirls",
+		"SQ": "squalls", "FC": "funnel cloud", "SS": "sandstorm",
+		"DS": "duststorm",
+	}
+)
+
+type WeatherAnnotator struct {
+	Annotator
+	Module
+	// Decode METAR, SPECI, TAF and D-ATIS reports in the message text into fields under ACARSProcessor.Weather, with the flight category and a summary. Runs whenever this section is configured unless set to false.
+	Enabled *bool `jsonschema:"default=true" default:"true"`
+	// Only provide these fields to future steps.
+	SelectedFields []string
+}
+
+// A decoded weather report. Fields the report doesn't have are left empty.
+type WeatherReport struct {
+	// METAR, SPECI, TAF or ATIS
+	Type    string
+	Station string
+	// Day, hour and minute (DDHHMM) for METARs and TAFs, or hour and minute
+	// (HHMM) for ATIS, in UTC
+	Time string
+	// The ATIS information letter
+	ATISCode             string
+	WindDirectionDegrees int64
+	// The wind direction is variable (VRB)
+	WindVariable   bool
+	WindSpeedKnots int64
+	WindGustKnots  int64
+	// 10 or more is reported as 10
+	VisibilityStatuteMiles float64
+	// Height of the lowest broken or overcast layer (or vertical
+	// visibility), zero if there isn't one
+	CeilingFeet int64
+	// VFR, MVFR, IFR or LIFR
+	FlightCategory     string
+	TemperatureCelsius *int64
+	DewpointCelsius    *int64
+	AltimeterInHg      float64
+	AltimeterHPa       int64
+	// Weather like -RA or +TSRA, separated by spaces
+	Phenomena string
+	// Cloud layers like FEW008 BKN200, separated by spaces
+	Clouds string
+	// The report as it was in the message
+	Raw string
+
+	hasWind       bool
+	hasVisibility bool
+}
+
+// Fields about all the reports in the message
+type WeatherSummary struct {
+	ReportCount int
+	// Stations with reports, separated by commas
+	Stations string
+	// The worst flight category of any report
+	WorstFlightCategory string
+	// Every report, decoded and on its own line
+	Summary string
+}
+
+func (a WeatherAnnotator) Name() string {
+	return reflect.TypeOf(a).Name()
+}
+
+func (a WeatherAnnotator) Configured() bool {
+	return !reflect.DeepEqual(a, WeatherAnnotator{})
+}
+
+func (a WeatherAnnotator) GetDefaultFields() (s []string) {
+	var t int64
+	r := WeatherReport{TemperatureCelsius: &t, DewpointCelsius: &t}
+	for f := range MergeAPMessages(FormatAsAPMessage(r, WeatherPrefix), FormatAsAPMessage(WeatherSummary{}, WeatherPrefix)) {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// Finds and decodes every METAR, SPECI, TAF and ATIS in text
+func DecodeWeather(text string) (reports []WeatherReport) {
+	type start struct {
+		index int
+		r     WeatherReport
+	}
+	var starts []start
+	for _, m := range weatherReportRegex.FindAllStringSubmatchIndex(text, -1) {
+		r := WeatherReport{Type: "METAR", Station: text[m[4]:m[5]], Time: text[m[6]:m[7]]}
+		if m[2] >= 0 {
+			r.Type = text[m[2]:m[3]]
+		}
+		starts = append(starts, start{index: m[0], r: r})
+	}
+	for _, m := range atisRegex.FindAllStringSubmatchIndex(text, -1) {
+		r := WeatherReport{Type: "ATIS", Station: text[m[2]:m[3]], ATISCode: text[m[4]:m[5]], Time: text[m[6]:m[7]]}
+		starts = append(starts, start{index: m[0], r: r})
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].index < starts[j].index })
+	for i, s := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1].index
+		}
+		raw := text[s.index:end]
+		// Reports often end with =
+		if j := strings.Index(raw, "="); j >= 0 {
+			raw = raw[:j]
+		}
+		r := s.r
+		r.Raw = strings.Join(strings.Fields(raw), " ")
+		// Something else that happens to look like a station and time
+		if !r.decode() {
+			continue
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// Fills in the fields from the groups after the station and time, ok is
+// false if there's no weather in them
+func (r *WeatherReport) decode() (ok bool) {
+	tokens := strings.Fields(r.Raw)
+	// Skip the header up to and including the time
+	for i, t := range tokens {
+		if strings.TrimRight(t, ".") == r.Time+"Z" {
+			tokens = tokens[i+1:]
+			break
+		}
+	}
+	if len(tokens) > 0 && tafValidityRegex.MatchString(tokens[0]) {
+		r.Type = "TAF"
+		tokens = tokens[1:]
+	}
+	var clouds, phenomena []string
+	for i := 0; i < len(tokens); i++ {
+		t := strings.TrimRight(tokens[i], ".,")
+		if t == "RMK" || (r.Type == "TAF" && tafChangeRegex.MatchString(t)) {
+			break
+		}
+		switch {
+		case t == "AUTO" || t == "COR" || t == "NIL":
+		case windRegex.MatchString(t):
+			m := windRegex.FindStringSubmatch(t)
+			r.hasWind = true
+			r.WindVariable = m[1] == "VRB"
+			if !r.WindVariable {
+				r.WindDirectionDegrees, _ = strconv.ParseInt(m[1], 10, 64)
+			}
+			r.WindSpeedKnots = windKnots(m[2], m[4])
+			if m[3] != "" {
+				r.WindGustKnots = windKnots(m[3], m[4])
+			}
+		case t == "CAVOK":
+			r.VisibilityStatuteMiles, r.hasVisibility = 10, true
+		case visibilityRegex.MatchString(t) && !r.hasVisibility:
+			m := visibilityRegex.FindStringSubmatch(t)
+			r.VisibilityStatuteMiles = parseFraction(m[2])
+			// Like 1 1/2SM
+			if i > 0 && strings.Contains(m[2], "/") {
+				if whole, err := strconv.Atoi(tokens[i-1]); err == nil && whole < 10 {
+					r.VisibilityStatuteMiles += float64(whole)
+				}
+			}
+			r.VisibilityStatuteMiles = math.Min(r.VisibilityStatuteMiles, 10)
+			r.hasVisibility = true
+		case metricVisibilityRegex.MatchString(t) && !r.hasVisibility:
+			meters, _ := strconv.Atoi(metricVisibilityRegex.FindStringSubmatch(t)[1])
+			r.VisibilityStatuteMiles = math.Min(math.Round(float64(meters)/1609.344*100)/100, 10)
+			r.hasVisibility = true
+		case cloudRegex.MatchString(t):
+			m := cloudRegex.FindStringSubmatch(t)
+			clouds = append(clouds, t)
+			height, err := strconv.ParseInt(m[2], 10, 64)
+			if err == nil && (m[1] == "BKN" || m[1] == "OVC" || m[1] == "VV") && r.CeilingFeet == 0 {
+				r.CeilingFeet = height * 100
+			}
+		case t == "SKC" || t == "CLR" || t == "NSC" || t == "NCD":
+			clouds = append(clouds, t)
+		case temperatureRegex.MatchString(t) && r.TemperatureCelsius == nil:
+			m := temperatureRegex.FindStringSubmatch(t)
+			temperature := parseWeatherTemperature(m[1])
+			r.TemperatureCelsius = &temperature
+			if m[2] != "" {
+				dewpoint := parseWeatherTemperature(m[2])
+				r.DewpointCelsius = &dewpoint
+			}
+		case altimeterRegex.MatchString(t):
+			m := altimeterRegex.FindStringSubmatch(t)
+			n, _ := strconv.ParseFloat(m[2], 64)
+			if m[1] == "A" {
+				r.AltimeterInHg = n / 100
+				r.AltimeterHPa = int64(math.Round(n / 100 * 33.8639))
+			} else {
+				r.AltimeterHPa = int64(n)
+				r.AltimeterInHg = math.Round(n/33.8639*100) / 100
+			}
+			// ATIS goes on with approaches and NOTAMs after the weather
+			if r.Type == "ATIS" {
+				i = len(tokens)
+			}
+		case len(t) >= 2 && phenomenaRegex.MatchString(t) && t != "VC":
+			phenomena = append(phenomena, t)
+		}
+	}
+	r.Clouds = strings.Join(clouds, " ")
+	r.Phenomena = strings.Join(phenomena, " ")
+	r.FlightCategory = FlightCategory(r.CeilingFeet, r.VisibilityStatuteMiles, r.hasVisibility)
+	return r.hasWind || r.hasVisibility || len(clouds) > 0 || r.TemperatureCelsius != nil || r.AltimeterHPa != 0
+}
+
+func windKnots(speed, unit string) int64 {
+	n, _ := strconv.ParseFloat(speed, 64)
+	switch unit {
+	case "MPS":
+		n *= 1.94384
+	case "KMH":
+		n /= 1.852
+	}
+	return int64(math.Round(n))
+}
+
+// Parses 10, 1/2 or 3/4
+func parseFraction(s string) float64 {
+	if numerator, denominator, ok := strings.Cut(s, "/"); ok {
+		n, _ := strconv.ParseFloat(numerator, 64)
+		d, _ := strconv.ParseFloat(denominator, 64)
+		if d == 0 {
+			return 0
+		}
+		return n / d
+	}
+	n, _ := strconv.ParseFloat(s, 64)
+	return n
+}
+
+// Parses temperatures like 17 or M02 (-2)
+func parseWeatherTemperature(s string) int64 {
+	n, _ := strconv.ParseInt(strings.TrimPrefix(s, "M"), 10, 64)
+	if strings.HasPrefix(s, "M") {
+		return -n
+	}
+	return n
+}
+
+// Returns the FAA flight category for a ceiling (zero if there isn't one)
+// and visibility, or nothing if there's neither
+func FlightCategory(ceilingFeet int64, visibilityStatuteMiles float64, hasVisibility bool) string {
+	if ceilingFeet == 0 && !hasVisibility {
+		return ""
+	}
+	// No ceiling is as good as an unlimited one
+	ceiling := float64(ceilingFeet)
+	if ceilingFeet == 0 {
+		ceiling = math.Inf(1)
+	}
+	visibility := visibilityStatuteMiles
+	if !hasVisibility {
+		visibility = math.Inf(1)
+	}
+	switch {
+	case ceiling < 500 || visibility < 1:
+		return "LIFR"
+	case ceiling < 1000 || visibility < 3:
+		return "IFR"
+	case ceiling <= 3000 || visibility <= 5:
+		return "MVFR"
+	}
+	return "VFR"
+}
+
+// Returns the worse of two flight categories
+func WorseFlightCategory(a, b string) string {
+	if slices.Index(FlightCategories, b) > slices.Index(FlightCategories, a) {
+		return b
+	}
+	return a
+}
+
+// A human-readable description of the report, like "METAR KSFO (San
+// Francisco International Airport) at 161856Z: VFR, wind 280° at 14 kt..."
+func (r WeatherReport) Describe() string {
+	header := fmt.Sprintf("%s %s", r.Type, r.Station)
+	if a, ok := Airports.Find(r.Station); ok {
+		header += fmt.Sprintf(" (%s)", a.Name)
+	}
+	if r.ATISCode != "" {
+		header += " information " + r.ATISCode
+	}
+	header += fmt.Sprintf(" at %sZ", r.Time)
+	var parts []string
+	if r.FlightCategory != "" {
+		parts = append(parts, r.FlightCategory)
+	}
+	if r.hasWind {
+		wind := fmt.Sprintf("wind %03d° at %d kt", r.WindDirectionDegrees, r.WindSpeedKnots)
+		switch {
+		case r.WindSpeedKnots == 0:
+			wind = "wind calm"
+		case r.WindVariable:
+			wind = fmt.Sprintf("wind variable at %d kt", r.WindSpeedKnots)
+		}
+		if r.WindGustKnots > 0 {
+			wind += fmt.Sprintf(" gusting %d kt", r.WindGustKnots)
+		}
+		parts = append(parts, wind)
+	}
+	if r.hasVisibility {
+		parts = append(parts, fmt.Sprintf("visibility %s SM", strconv.FormatFloat(r.VisibilityStatuteMiles, 'f', -1, 64)))
+	}
+	for _, p := range strings.Fields(r.Phenomena) {
+		parts = append(parts, DescribeWeatherPhenomena(p))
+	}
+	for _, c := range strings.Fields(r.Clouds) {
+		m := cloudRegex.FindStringSubmatch(c)
+		if m == nil {
+			parts = append(parts, "clear skies")
+			continue
+		}
+		layer := cloudCoverDescriptions[m[1]]
+		if height, err := strconv.Atoi(m[2]); err == nil {
+			layer += fmt.Sprintf(" at %d ft", height*100)
+		}
+		switch m[3] {
+		case "CB":
+			layer += " (cumulonimbus)"
+		case "TCU":
+			layer += " (towering cumulus)"
+		}
+		parts = append(parts, layer)
+	}
+	if r.TemperatureCelsius != nil {
+		parts = append(parts, fmt.Sprintf("temperature %d°C", *r.TemperatureCelsius))
+	}
+	if r.DewpointCelsius != nil {
+		parts = append(parts, fmt.Sprintf("dewpoint %d°C", *r.DewpointCelsius))
+	}
+	if r.AltimeterInHg != 0 {
+		parts = append(parts, fmt.Sprintf("altimeter %.2f inHg (%d hPa)", r.AltimeterInHg, r.AltimeterHPa))
+	}
+	if len(parts) == 0 {
+		return header
+	}
+	return header + ": " + strings.Join(parts, ", ")
+}
+
+// Describes weather like +TSRA as "heavy thunderstorms with rain"
+func DescribeWeatherPhenomena(p string) string {
+	m := phenomenaRegex.FindStringSubmatch(p)
+	if m == nil {
+		return p
+	}
+	var words []string
+	for _, code := range m[1:3] {
+		if code != "" {
+			words = append(words, phenomenaDescriptions[code])
+		}
+	}
+	var kinds []string
+	for i := 0; i+2 <= len(m[3]); i += 2 {
+		kinds = append(kinds, phenomenaDescriptions[m[3][i:i+2]])
+	}
+	words = append(words, strings.Join(kinds, " and "))
+	return strings.TrimSpace(strings.Join(words, " "))
+}
+
+func (a WeatherAnnotator) Annotate(m APMessage) (APMessage, error) {
+	if a.Enabled != nil && !*a.Enabled {
+		return m, nil
+	}
+	reports := DecodeWeather(GetAPMessageCommonFieldAsString(m, "MessageText"))
+	if len(reports) == 0 {
+		return m, nil
+	}
+	s := WeatherSummary{ReportCount: len(reports)}
+	var stations, descriptions []string
+	for _, r := range reports {
+		if !slices.Contains(stations, r.Station) {
+			stations = append(stations, r.Station)
+		}
+		descriptions = append(descriptions, r.Describe())
+		s.WorstFlightCategory = WorseFlightCategory(s.WorstFlightCategory, r.FlightCategory)
+	}
+	s.Stations = strings.Join(stations, ",")
+	s.Summary = strings.Join(descriptions, "\n")
+	// The first report's fields, and the summary of all of them
+	apm := MergeAPMessages(FormatAsAPMessage(reports[0], WeatherPrefix), FormatAsAPMessage(s, WeatherPrefix))
+	// Remove all but any selected fields
+	if len(a.SelectedFields) > 0 {
+		for field := range apm {
+			if !slices.Contains(a.SelectedFields, field) {
+				delete(apm, field)
+			}
+		}
+	}
+	return MergeAPMessages(m, apm), nil
+}
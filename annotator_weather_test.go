@@ -0,0 +1,230 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeWeather(t *testing.T) {
+	celsius := func(n int64) *int64 { return &n }
+	tests := []struct {
+		name string
+		text string
+		want []WeatherReport
+	}{
+		{
+			name: "US METAR",
+			text: "METAR KSFO 161856Z 28014G22KT 1 1/2SM -RA BR BKN008 OVC015 17/11 A3002 RMK AO2=",
+			want: []WeatherReport{{
+				Type:                   "METAR",
+				Station:                "KSFO",
+				Time:                   "161856",
+				WindDirectionDegrees:   280,
+				WindSpeedKnots:         14,
+				WindGustKnots:          22,
+				VisibilityStatuteMiles: 1.5,
+				CeilingFeet:            800,
+				FlightCategory:         "IFR",
+				TemperatureCelsius:     celsius(17),
+				DewpointCelsius:        celsius(11),
+				AltimeterInHg:          30.02,
+				AltimeterHPa:           1017,
+				Phenomena:              "-RA BR",
+				Clouds:                 "BKN008 OVC015",
+				Raw:                    "METAR KSFO 161856Z 28014G22KT 1 1/2SM -RA BR BKN008 OVC015 17/11 A3002 RMK AO2",
+				hasWind:                true,
+				hasVisibility:          true,
+			}},
+		},
+		{
+			name: "metric METAR",
+			text: "METAR EGLL 161850Z 24008MPS 9999 FEW035 SCT250 M02/M05 Q1013 NOSIG=",
+			want: []WeatherReport{{
+				Type:                   "METAR",
+				Station:                "EGLL",
+				Time:                   "161850",
+				WindDirectionDegrees:   240,
+				WindSpeedKnots:         16,
+				VisibilityStatuteMiles: 6.21,
+				FlightCategory:         "VFR",
+				TemperatureCelsius:     celsius(-2),
+				DewpointCelsius:        celsius(-5),
+				AltimeterInHg:          29.91,
+				AltimeterHPa:           1013,
+				Clouds:                 "FEW035 SCT250",
+				Raw:                    "METAR EGLL 161850Z 24008MPS 9999 FEW035 SCT250 M02/M05 Q1013 NOSIG",
+				hasWind:                true,
+				hasVisibility:          true,
+			}},
+		},
+		{
+			name: "metric METAR without a type in fog",
+			text: "LFPG 161830Z 18020KMH 0800 FG VV002 08/08 Q1020",
+			want: []WeatherReport{{
+				Type:                   "METAR",
+				Station:                "LFPG",
+				Time:                   "161830",
+				WindDirectionDegrees:   180,
+				WindSpeedKnots:         11,
+				VisibilityStatuteMiles: 0.5,
+				CeilingFeet:            200,
+				FlightCategory:         "LIFR",
+				TemperatureCelsius:     celsius(8),
+				DewpointCelsius:        celsius(8),
+				AltimeterInHg:          30.12,
+				AltimeterHPa:           1020,
+				Phenomena:              "FG",
+				Clouds:                 "VV002",
+				Raw:                    "LFPG 161830Z 18020KMH 0800 FG VV002 08/08 Q1020",
+				hasWind:                true,
+				hasVisibility:          true,
+			}},
+		},
+		{
+			name: "TAF stops at the first change group",
+			text: "TAF KSFO 161720Z 1618/1724 28012KT P6SM FEW010 BKN025 FM170200 30008KT P6SM SKC",
+			want: []WeatherReport{{
+				Type:                   "TAF",
+				Station:                "KSFO",
+				Time:                   "161720",
+				WindDirectionDegrees:   280,
+				WindSpeedKnots:         12,
+				VisibilityStatuteMiles: 6,
+				CeilingFeet:            2500,
+				FlightCategory:         "MVFR",
+				Clouds:                 "FEW010 BKN025",
+				Raw:                    "TAF KSFO 161720Z 1618/1724 28012KT P6SM FEW010 BKN025 FM170200 30008KT P6SM SKC",
+				hasWind:                true,
+				hasVisibility:          true,
+			}},
+		},
+		{
+			name: "amended TAF with variable wind",
+			text: "TAF AMD KJFK 161900Z 1619/1724 VRB03KT 1/2SM FG OVC002 TEMPO 1620/1622 3SM BR",
+			want: []WeatherReport{{
+				Type:                   "TAF",
+				Station:                "KJFK",
+				Time:                   "161900",
+				WindVariable:           true,
+				WindSpeedKnots:         3,
+				VisibilityStatuteMiles: 0.5,
+				CeilingFeet:            200,
+				FlightCategory:         "LIFR",
+				Phenomena:              "FG",
+				Clouds:                 "OVC002",
+				Raw:                    "TAF AMD KJFK 161900Z 1619/1724 VRB03KT 1/2SM FG OVC002 TEMPO 1620/1622 3SM BR",
+				hasWind:                true,
+				hasVisibility:          true,
+			}},
+		},
+		{
+			name: "ATIS stops after the altimeter",
+			text: "KSFO ATIS INFO B 1856Z. 28014KT 10SM FEW008 17/11 A3002. ILS RWY 28L APCH IN USE. BIRD ACTIVITY BKN005 REPORTED.",
+			want: []WeatherReport{{
+				Type:                   "ATIS",
+				Station:                "KSFO",
+				Time:                   "1856",
+				ATISCode:               "B",
+				WindDirectionDegrees:   280,
+				WindSpeedKnots:         14,
+				VisibilityStatuteMiles: 10,
+				FlightCategory:         "VFR",
+				TemperatureCelsius:     celsius(17),
+				DewpointCelsius:        celsius(11),
+				AltimeterInHg:          30.02,
+				AltimeterHPa:           1017,
+				Clouds:                 "FEW008",
+				Raw:                    "KSFO ATIS INFO B 1856Z. 28014KT 10SM FEW008 17/11 A3002. ILS RWY 28L APCH IN USE. BIRD ACTIVITY BKN005 REPORTED.",
+				hasWind:                true,
+				hasVisibility:          true,
+			}},
+		},
+		{
+			name: "two reports",
+			text: "KSFO 161856Z 00000KT CAVOK 17/11 A3002= KOAK 161853Z 29010KT 2SM BR OVC004 15/14 A3001=",
+			want: []WeatherReport{
+				{
+					Type:                   "METAR",
+					Station:                "KSFO",
+					Time:                   "161856",
+					VisibilityStatuteMiles: 10,
+					FlightCategory:         "VFR",
+					TemperatureCelsius:     celsius(17),
+					DewpointCelsius:        celsius(11),
+					AltimeterInHg:          30.02,
+					AltimeterHPa:           1017,
+					Raw:                    "KSFO 161856Z 00000KT CAVOK 17/11 A3002",
+					hasWind:                true,
+					hasVisibility:          true,
+				},
+				{
+					Type:                   "METAR",
+					Station:                "KOAK",
+					Time:                   "161853",
+					WindDirectionDegrees:   290,
+					WindSpeedKnots:         10,
+					VisibilityStatuteMiles: 2,
+					CeilingFeet:            400,
+					FlightCategory:         "LIFR",
+					TemperatureCelsius:     celsius(15),
+					DewpointCelsius:        celsius(14),
+					AltimeterInHg:          30.01,
+					AltimeterHPa:           1016,
+					Phenomena:              "BR",
+					Clouds:                 "OVC004",
+					Raw:                    "KOAK 161853Z 29010KT 2SM BR OVC004 15/14 A3001",
+					hasWind:                true,
+					hasVisibility:          true,
+				},
+			},
+		},
+		{name: "station and time without weather", text: "POS KSFO 161856Z FL350 ETA KLAX"},
+		{name: "empty", text: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DecodeWeather(tt.text)
+			if len(got) != len(tt.want) {
+				t.Fatalf("decoded %d reports, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
+					t.Errorf("report %d decoded %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFlightCategory(t *testing.T) {
+	tests := []struct {
+		name          string
+		ceilingFeet   int64
+		visibility    float64
+		hasVisibility bool
+		want          string
+	}{
+		{name: "ceiling below 500", ceilingFeet: 400, visibility: 10, hasVisibility: true, want: "LIFR"},
+		{name: "ceiling 500", ceilingFeet: 500, visibility: 10, hasVisibility: true, want: "IFR"},
+		{name: "ceiling 900", ceilingFeet: 900, visibility: 10, hasVisibility: true, want: "IFR"},
+		{name: "ceiling 1000", ceilingFeet: 1000, visibility: 10, hasVisibility: true, want: "MVFR"},
+		{name: "ceiling 3000", ceilingFeet: 3000, visibility: 10, hasVisibility: true, want: "MVFR"},
+		{name: "ceiling 3100", ceilingFeet: 3100, visibility: 10, hasVisibility: true, want: "VFR"},
+		{name: "visibility below 1", visibility: 0.75, hasVisibility: true, want: "LIFR"},
+		{name: "visibility 1", visibility: 1, hasVisibility: true, want: "IFR"},
+		{name: "visibility 2.5", visibility: 2.5, hasVisibility: true, want: "IFR"},
+		{name: "visibility 3", visibility: 3, hasVisibility: true, want: "MVFR"},
+		{name: "visibility 5", visibility: 5, hasVisibility: true, want: "MVFR"},
+		{name: "visibility 6", visibility: 6, hasVisibility: true, want: "VFR"},
+		{name: "worse of ceiling and visibility", ceilingFeet: 5000, visibility: 0.5, hasVisibility: true, want: "LIFR"},
+		{name: "ceiling without visibility", ceilingFeet: 800, want: "IFR"},
+		{name: "neither", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FlightCategory(tt.ceilingFeet, tt.visibility, tt.hasVisibility); got != tt.want {
+				t.Errorf("FlightCategory(%d, %v, %v) = %q, want %q", tt.ceilingFeet, tt.visibility, tt.hasVisibility, got, tt.want)
+			}
+		})
+	}
+}
@@ -16,9 +16,18 @@ const (
 
 func (as AnnotateStep) Annotate(m APMessage) APMessage {
 	annotators := []Annotator{
+		as.Label,
+		as.Airline,
+		as.AircraftDatabase,
+		as.Decoder,
+		as.Weather,
+		as.Clearance,
 		as.ADSB,
 		as.Ollama,
 		as.Tar1090,
+		as.Readsb,
+		as.Airport,
+		as.Planespotters,
 	}
 	for _, a := range annotators {
 		if !a.Configured() {
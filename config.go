@@ -75,6 +75,30 @@ func LoadConfig() {
 				CompiledRegexes[term] = exp
 		}
 	}
+
+	if err := ValidateStations(config.Stations); err != nil {
+		log.Fatal(Attention("invalid Stations config: %s", err))
+	}
+
+	// Check geofences now so a bad zone or GeoJSON file stops startup instead
+	// of failing every message
+	for _, step := range config.Steps {
+		if g := step.Filter.Geofence; g.Configured() {
+			if _, err := g.Geofences(); err != nil {
+				log.Fatal(Attention("%s: %s", g.Name(), err))
+			}
+		}
+	}
+
+	// Aircraft databases can be large, so load them now rather than when the
+	// first message comes in
+	for _, step := range config.Steps {
+		if ad := step.Annotate.AircraftDatabase; ad.Configured() {
+			if _, err := GetAircraftDatabase(ad.DatabaseFile); err != nil {
+				log.Error(Attention("%s: %s", ad.Name(), err))
+			}
+		}
+	}
 }
 
 // Special message format internal to ACARS-Processor. Not ACARS/VDLM2 specific.
@@ -85,10 +109,21 @@ type APMessage map[string]any
 type Config struct {
 	// These control acars-processor itself
 	ACARSProcessorSettings ACARSProcessorSettings `jsonschema:"required"`
+	// Where your receiving stations are, keyed by station ID (ACARSProcessor.StationId). Annotators measure distances from the station that heard a message, or their ReferenceGeolocation if it isn't listed here.
+	Stations map[string]StationConfig `json:",omitempty"`
 	// Actions to take on messages in the order they should be taken.
 	Steps []ProcessingStep
 }
 
+type StationConfig struct {
+	// Name of the station, added to messages with distances.
+	Name string
+	// Where the station is (LAT,LON).
+	Geolocation string `jsonschema:"required" default:"35.6244416,139.7753782"`
+	// Height of the antenna above sea level in meters, for elevation angles.
+	ElevationMeters float64
+}
+
 type ACARSProcessorSettings struct {
 	// Force whether or not color output is used.
 	ColorOutput bool `json:",omitempty" jsonschema:"default=true" default:"true"`
@@ -99,7 +134,19 @@ type ACARSProcessorSettings struct {
 	// Whether to refrain from printing timestamps in logs.
 	LogHideTimestamps bool `json:",omitempty" jsonschema:"default=false" default:"false"`
 	// ACARSHub connection settings.
-	ACARSHub ACARSHubConfig `jsonschema:"required"`
+	ACARSHub ACARSHubConfig
+	// Receive JSON directly from decoders like acarsdec and dumpvdl2 (or acars_router) without ACARSHub.
+	Listen ListenerConfig `json:",omitempty"`
+	// Accept messages pushed over HTTP.
+	HTTPIngest HTTPIngestConfig `json:",omitempty"`
+	// Subscribe to messages published to an MQTT broker.
+	MQTT MQTTConfig `json:",omitempty"`
+	// Combine messages sent in multiple blocks before processing them.
+	Reassembly ReassemblyConfig `json:",omitempty"`
+	// Combine copies of the same message heard by more than one station.
+	Deduplication DeduplicationConfig `json:",omitempty"`
+	// Link messages to and from the same aircraft into conversations.
+	Threading ThreadingConfig `json:",omitempty"`
 }
 
 type ACARSProcessorDatabaseConfig struct {
@@ -118,10 +165,90 @@ type ACARSHubConfig struct {
 	ACARS ACARSConnectionConfig
 	// VDLM2-specific settings when connecting to ACARSHub.
 	VDLM2 VDLM2ConnectionConfig
+	// HFDL-specific settings when connecting to ACARSHub.
+	HFDL HFDLConnectionConfig
 	// Maximum number of requests from ACARSHub to process at once.
 	MaxConcurrentRequests int
 }
 
+type ListenerConfig struct {
+	// Listen for ACARS JSON, such as from acarsdec.
+	ACARS ListenerConnectionConfig
+	// Listen for VDLM2 JSON, such as from dumpvdl2.
+	VDLM2 ListenerConnectionConfig
+	// Listen for HFDL JSON, such as from dumphfdl.
+	HFDL ListenerConnectionConfig
+	// Listen for Inmarsat/Iridium SATCOM ACARS JSON, such as from JAERO or acars_router.
+	SATCOM SatcomListenerConfig
+}
+
+type ListenerConnectionConfig struct {
+	// Address to listen on.
+	Host string `jsonschema:"default=0.0.0.0" default:"0.0.0.0"`
+	// UDP port to listen on for JSON messages. Leave unset to not listen on UDP.
+	UDPPort int `json:",omitempty" jsonschema:"example=5550" default:"5550"`
+	// TCP port to listen on for JSON messages. Leave unset to not listen on TCP.
+	TCPPort int `json:",omitempty" jsonschema:"example=5550" default:"5550"`
+}
+
+type SatcomListenerConfig struct {
+	Module
+	ListenerConnectionConfig
+	// Name of the satellite being received, used if the decoder doesn't send one (JAERO does not).
+	Satellite string `json:",omitempty" jsonschema:"example=Inmarsat 4-F3 (98W)" default:"Inmarsat 4-F3 (98W)"`
+	// Only provide these fields to configured steps.
+	SelectedFields []string
+}
+
+type HTTPIngestConfig struct {
+	// Address to listen on.
+	Host string `jsonschema:"default=0.0.0.0" default:"0.0.0.0"`
+	// Port to serve the ingest endpoint (POST /ingest) on. Leave unset to disable.
+	Port int `json:",omitempty" jsonschema:"example=8080" default:"8080"`
+	// If set, requests must have an "Authorization: Bearer <token>" header with this token.
+	BearerToken string `json:",omitempty" default:"your token here"`
+	// Largest request body to accept, in bytes.
+	MaxBodyBytes int64 `json:",omitempty" jsonschema:"default=10485760" default:"10485760"`
+}
+
+type MQTTConfig struct {
+	// Broker to connect to. Leave unset to not use MQTT.
+	Broker string `json:",omitempty" jsonschema:"example=tcp://mosquitto:1883,example=ssl://broker.example.com:8883" default:"tcp://mosquitto:1883"`
+	// Client ID to connect with, must be unique on the broker.
+	ClientID string `json:",omitempty" jsonschema:"default=acars-processor" default:"acars-processor"`
+	// Username, if the broker requires one.
+	Username string `json:",omitempty" default:"your username here"`
+	// Password, if the broker requires one.
+	Password string `json:",omitempty" default:"your password here"`
+	// Topics to subscribe to, MQTT wildcards (+ and #) are supported. Payloads can be ACARS, VDLM2, HFDL or SATCOM JSON.
+	Topics []string `json:",omitempty" jsonschema:"example=[acars/#]" default:"[acars/#]"`
+	// Quality of service level to subscribe with.
+	QoS byte `json:",omitempty" jsonschema:"enum=0,enum=1,enum=2,default=0" default:"0"`
+}
+
+type ReassemblyConfig struct {
+	// Whether to hold blocks of multi-block messages and process them as one message.
+	Enabled bool `json:",omitempty" jsonschema:"default=false" default:"false"`
+	// How long to wait for the rest of a message's blocks before processing the blocks that were received.
+	TimeoutSeconds int `json:",omitempty" jsonschema:"default=30" default:"30"`
+}
+
+type DeduplicationConfig struct {
+	// Whether to hold messages briefly so copies from other stations can be combined with them.
+	Enabled bool `json:",omitempty" jsonschema:"default=false" default:"false"`
+	// How long to hold the first copy of a message waiting for others. Messages from different stations with the same tail, flight, message number, block ID, label and text within this time are copies.
+	WindowSeconds float64 `json:",omitempty" jsonschema:"default=3" default:"3"`
+}
+
+type ThreadingConfig struct {
+	// Whether to track conversations between aircraft and the ground.
+	Enabled bool `json:",omitempty" jsonschema:"default=false" default:"false"`
+	// How long a conversation can go without a message before the next message starts a new one.
+	TimeoutMinutes int `json:",omitempty" jsonschema:"default=15" default:"15"`
+	// Only add messages with these labels to conversations. All messages with text are added if unset.
+	Labels []string `json:",omitempty" jsonschema:"example=[H1,RA,C1]" default:"[H1,RA,C1]"`
+}
+
 type ACARSJSONConnection struct {
 	// IP or DNS to your ACARSHub instance serving JSON data from a particular port.
 	Host string `jsonschema:"required,default=acarshub" default:"acarshub"`
@@ -145,6 +272,15 @@ type VDLM2ConnectionConfig struct {
 	SelectedFields []string
 }
 
+type HFDLConnectionConfig struct {
+	Module
+	ACARSJSONConnection
+	// HFDL JSON port.
+	Port int `jsonschema:"required,default=15556" default:"15556"`
+	// Only provide these fields to configured steps.
+	SelectedFields []string
+}
+
 // A module is a standard component of a ProcessingStep. Source (internal only
 // such as ACARS/VDLM2 feeders), Annotator, Filter, Receiver are all components
 // of their ProcessingSteps.
@@ -158,9 +294,10 @@ type Module interface {
 }
 
 // Here we add some extra fields for convenience and to have common
-// fields between ACARS and VDLM2 when each has a different measuring unit
-// Only ACARS and VDLM2, there probably won't be more sources.
+// fields between sources (ACARS, VDLM2, HFDL and SATCOM) when each has a
+// different measuring unit
 type Source interface {
+	Name() string
 	Prepare() APMessage
 }
 
@@ -200,6 +337,8 @@ type ProcessingStep struct {
 type FilterStep struct {
 	// Built-in filters
 	Builtin BuiltinFilter
+	// Only process messages from aircraft inside polygons (from the config or GeoJSON files) and altitude bands.
+	Geofence GeofenceFilter
 	// Use Ollama (which can be self-hosted) to choose to filter messages based on plain-text criteria.
 	Ollama OllamaFilterer
 	// Use OpenAI to choose to filter messages based on plain-text criteria.
@@ -215,6 +354,24 @@ type AnnotateStep struct {
 	Ollama OllamaAnnotator
 	// 	// Look up geolocation, including distance from a reference point to aircraft, from ADSB-Exchange
 	ADSB ADSBExchangeAnnotator
+	// Decode label-specific formats like OOOI times, position reports and flight plans into fields under ACARSProcessor.Decoded
+	Decoder DecoderAnnotator
+	// Describe and categorize message labels (like OOOI, Weather or Maintenance) from a built-in table you can override
+	Label LabelAnnotator
+	// Split flight numbers into airline and number and add airline details from a built-in database
+	Airline AirlineAnnotator
+	// Add aircraft details like type, operator and year from a local aircraft database, looked up by ICAO hex or tail code
+	AircraftDatabase AircraftDatabaseAnnotator
+	// Keep a live table of aircraft from a local readsb or dump1090 SBS (BaseStation) or JSON feed and add the latest position, altitude, speed and squawk
+	Readsb ReadsbAnnotator
+	// Find airports, runways and waypoints in message text, guess the origin and destination from a built-in airport database, and optionally estimate the position from them
+	Airport AirportAnnotator
+	// Decode METAR, SPECI, TAF and D-ATIS reports in message text into fields under ACARSProcessor.Weather, including the flight category and a human-readable summary
+	Weather WeatherAnnotator
+	// Parse pre-departure, departure and oceanic clearances in message text into fields like the SID, squawk, altitude, departure frequency, route and NAT track
+	Clearance ClearanceAnnotator
+	// Add a photo of the aircraft and the photographer's credit from planespotters.net, cached so each aircraft is only looked up occasionally
+	Planespotters PlanespottersAnnotator
 }
 
 type ReceiverStep struct {
@@ -36,7 +36,17 @@ func InitSQLite() (err error) {
 		log.Info(Success("Database path set to %s", sqlitePath))
 	}
 	db, err = gorm.Open(sqlite.Open(sqlitePath), &gorm.Config{})
-	return err
+	if err != nil {
+		return err
+	}
+	// SQLite only allows one writer at a time, and several sources (and
+	// listener connections) may be writing at once.
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return nil
 }
 
 func InitMariaDB() (err error) {
@@ -106,6 +116,55 @@ func LoadSavedMessages() error {
 		log.Info(Content("Loaded %d VDLM2 messages from the db", len(vm)))
 	}
 
+	// HFDL
+	hm := []HFDLMessage{}
+	if err := db.AutoMigrate(HFDLMessage{}); err != nil {
+		log.Fatal(Attention("Unable to automigrate HFDLMessage type: %s", err))
+	}
+
+	if config.ACARSProcessorSettings.Database.Enabled {
+		db.Where("processed = ?", false).Find(&hm)
+		for _, h := range hm {
+			APMessageQueue <- APMessageQeueueItem{
+				HFDLMessage: h,
+				APMessage:   h.Prepare(),
+			}
+		}
+		log.Info(Content("Loaded %d HFDL messages from the db", len(hm)))
+	}
+
+	// SATCOM
+	sm := []SatcomMessage{}
+	if err := db.AutoMigrate(SatcomMessage{}); err != nil {
+		log.Fatal(Attention("Unable to automigrate SatcomMessage type: %s", err))
+	}
+
+	if config.ACARSProcessorSettings.Database.Enabled {
+		db.Where("processed = ?", false).Find(&sm)
+		for _, s := range sm {
+			APMessageQueue <- APMessageQeueueItem{
+				SatcomMessage: s,
+				APMessage:     s.Prepare(),
+			}
+		}
+		log.Info(Content("Loaded %d SATCOM messages from the db", len(sm)))
+	}
+
+	// Conversations
+	if err := db.AutoMigrate(ConversationThread{}, ConversationPost{}); err != nil {
+		log.Fatal(Attention("Unable to automigrate conversation types: %s", err))
+	}
+
+	// Annotator API responses
+	if err := db.AutoMigrate(CachedHTTPResponse{}); err != nil {
+		log.Fatal(Attention("Unable to automigrate cached HTTP response type: %s", err))
+	}
+
+	// Planespotters photo cache
+	if err := db.AutoMigrate(PlanespottersPhoto{}); err != nil {
+		log.Fatal(Attention("Unable to automigrate Planespotters photo type: %s", err))
+	}
+
 	// Ollama filter
 	if err := db.AutoMigrate(OllamaFilterResult{}); err != nil {
 		log.Fatal(Attention("Unable to automigrate Ollama filter type: %s", err))
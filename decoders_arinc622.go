@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Ground station address, IMI, 7 character registration padded with
+// periods, then hex data ending in a 16 bit CRC
+var arinc622Regex = regexp.MustCompile(`/([A-Z0-9]{3,7})\.(AT1|CR1|CC1|DR1|ADS|DIS)([A-Z0-9.-]{7})((?:[0-9A-F]{2})*)$`)
+
+// ATS applications identified by their IMI (imbedded message identifier)
+var ARINC622IMIs = map[string]struct{ Application, Description string }{
+	"AT1": {"CPDLC", "CPDLC message"},
+	"CR1": {"CPDLC", "CPDLC connect request"},
+	"CC1": {"CPDLC", "CPDLC connect confirm"},
+	"DR1": {"CPDLC", "CPDLC disconnect request"},
+	"ADS": {"ADS-C", "ADS-C message"},
+	"DIS": {"ADS-C", "ADS-C disconnect request"},
+}
+
+// Fields from an ARINC 622 ATS message (CPDLC or ADS-C). Positions from
+// ADS-C reports are in the Decoded Latitude, Longitude and AltitudeFeet
+// fields.
+type ARINC622Message struct {
+	GroundStation string
+	IMI           string
+	// Whether the message passed its CRC check, fields may be garbage if not
+	CRCOK bool
+	CPDLC CPDLCMessage
+	ADSC  ADSCReport
+}
+
+// FANS-1/A CPDLC message header and first message element
+type CPDLCMessage struct {
+	// The message has up to four more elements after the first, which aren't
+	// decoded
+	MoreElements bool
+	MessageID    int
+	ReferenceID  int
+	// HH:MM:SS UTC, if the message has one
+	Timestamp string
+	// Message element identifier like UM80 or DM67
+	Element string
+	// The element's text with placeholders for parameters, like CLEARED [routeclearance]
+	ElementText string
+	// What the element is for, like "Route modification" or "Emergency"
+	Category string
+	// Text of free text elements
+	FreeText string
+}
+
+// FANS-1/A ADS-C report groups
+type ADSCReport struct {
+	ReportType string
+	Emergency  bool
+	// Seconds past the hour the position was reported at
+	ReportTimeSeconds        float64
+	FlightID                 string
+	AircraftICAOHex          string
+	NextWaypointLatitude     float64
+	NextWaypointLongitude    float64
+	NextWaypointAltitudeFeet int64
+	// Seconds until the aircraft reaches the next waypoint
+	NextWaypointETASeconds int64
+}
+
+// Returns a decoder for ARINC 622 messages. Uplink is true for labels sent
+// from the ground.
+func DecodeARINC622(uplink bool) LabelDecoder {
+	return func(text string) (d DecodedMessage, ok bool) {
+		m := arinc622Regex.FindStringSubmatch(text)
+		if m == nil || len(m[4]) < 4 {
+			return d, false
+		}
+		gs, imi, reg := m[1], m[2], m[3]
+		data, err := hex.DecodeString(m[4])
+		if err != nil {
+			return d, false
+		}
+		payload, crc := data[:len(data)-2], data[len(data)-2:]
+		app := ARINC622IMIs[imi]
+		d = DecodedMessage{
+			Type:               app.Application,
+			Subtype:            imi,
+			SubtypeDescription: app.Description,
+			ARINC622: ARINC622Message{
+				GroundStation: gs,
+				IMI:           imi,
+				CRCOK:         ARINC622CRCOK([]byte(imi+reg), payload, crc),
+			},
+		}
+		switch app.Application {
+		case "CPDLC":
+			d.ARINC622.CPDLC = DecodeCPDLC(payload, uplink)
+		case "ADS-C":
+			if uplink {
+				d.ARINC622.ADSC.ReportType = "Contract request"
+				break
+			}
+			d.ARINC622.ADSC = DecodeADSC(payload, &d)
+		}
+		return d, true
+	}
+}
+
+// What the CRC register holds after running over a message and its CRC, if
+// the CRC was sent high byte first and the message is intact
+const arinc622CRCResidue = 0x1D0F
+
+// CRC-16/GENIBUS of data, as the sender computes it
+func ARINC622CRC(data []byte) uint16 {
+	return arinc622CRCRegister(data) ^ 0xFFFF
+}
+
+func arinc622CRCRegister(data []byte) uint16 {
+	sum := uint16(0xFFFF)
+	for _, b := range data {
+		sum ^= uint16(b) << 8
+		for range 8 {
+			if sum&0x8000 != 0 {
+				sum = sum<<1 ^ 0x1021
+			} else {
+				sum <<= 1
+			}
+		}
+	}
+	return sum
+}
+
+// Checks the CRC over the IMI, registration and data the same way libacars
+// does, by running it over the CRC too and comparing to the residue
+func ARINC622CRCOK(header, payload, crc []byte) bool {
+	return arinc622CRCRegister(slices.Concat(header, payload, crc)) == arinc622CRCResidue
+}
+
+// Decodes the header and first element of an unaligned PER encoded FANS-1/A
+// CPDLC message. Element parameters aren't decoded other than free text, and
+// MoreElements says whether there are elements after the first.
+func DecodeCPDLC(payload []byte, uplink bool) (c CPDLCMessage) {
+	r := BitReader{Data: payload}
+	// Parameters aren't decoded, so there's no telling where the next
+	// element starts
+	c.MoreElements = r.Read(1) == 1
+	hasReference, hasTimestamp := r.Read(1) == 1, r.Read(1) == 1
+	c.MessageID = int(r.Read(6))
+	if hasReference {
+		c.ReferenceID = int(r.Read(6))
+	}
+	if hasTimestamp {
+		c.Timestamp = fmt.Sprintf("%02d:%02d:%02d", r.Read(5), r.Read(6), r.Read(6))
+	}
+	prefix, elements, categories, freeText := "DM", CPDLCDownlinkElements, CPDLCDownlinkCategories, []int{67, 68}
+	// 183 uplink elements need 8 bits, 81 downlink elements need 7
+	element := int(r.Read(7))
+	if uplink {
+		prefix, elements, categories, freeText = "UM", CPDLCUplinkElements, CPDLCUplinkCategories, []int{169, 170}
+		element = element<<1 | int(r.Read(1))
+	}
+	if r.Overrun || element >= len(elements) {
+		return c
+	}
+	c.Element = fmt.Sprintf("%s%d", prefix, element)
+	c.ElementText = elements[element]
+	c.Category = CPDLCCategory(categories, element)
+	if element == freeText[0] || element == freeText[1] {
+		// IA5String (SIZE (1..256)), 7 bits per character
+		length := int(r.Read(8)) + 1
+		var text strings.Builder
+		for range length {
+			text.WriteByte(byte(r.Read(7)))
+		}
+		if !r.Overrun {
+			c.FreeText = text.String()
+		}
+	}
+	return c
+}
+
+// Payload lengths of the ADS-C downlink groups we know how to skip over
+var adscGroupLengths = map[byte]int{
+	3: 1, 7: 10, 9: 10, 10: 10, 12: 6, 13: 17, 14: 5, 15: 5, 16: 4, 17: 3, 18: 10, 19: 10,
+}
+
+var adscReportTypes = map[byte]string{
+	3:  "Acknowledgement",
+	7:  "Basic report",
+	9:  "Emergency basic report",
+	10: "Lateral deviation change event",
+	18: "Vertical rate change event",
+	19: "Altitude range event",
+}
+
+// Decodes the basic, flight ID, predicted route and airframe ID groups of an
+// ADS-C downlink. The position goes in d.
+func DecodeADSC(payload []byte, d *DecodedMessage) (a ADSCReport) {
+	for len(payload) > 0 {
+		tag := payload[0]
+		length, known := adscGroupLengths[tag]
+		if !known || len(payload) < length+1 {
+			break
+		}
+		group := payload[1 : length+1]
+		payload = payload[length+1:]
+		if t, ok := adscReportTypes[tag]; ok && a.ReportType == "" {
+			a.ReportType = t
+		}
+		r := BitReader{Data: group}
+		switch tag {
+		case 7, 9, 10, 18, 19:
+			a.Emergency = a.Emergency || tag == 9
+			d.Latitude = ADSCCoordinate(r.Read(21))
+			d.Longitude = ADSCCoordinate(r.Read(21))
+			d.AltitudeFeet = int64(int16(r.Read(16))) * 4
+			a.ReportTimeSeconds = float64(r.Read(15)) * 0.125
+		case 12:
+			var id strings.Builder
+			for range 8 {
+				// ISO 5 characters packed in 6 bits
+				c := byte(r.Read(6))
+				if c < 0x20 {
+					c |= 0x40
+				}
+				id.WriteByte(c)
+			}
+			a.FlightID = strings.TrimSpace(id.String())
+		case 13:
+			a.NextWaypointLatitude = ADSCCoordinate(r.Read(21))
+			a.NextWaypointLongitude = ADSCCoordinate(r.Read(21))
+			a.NextWaypointAltitudeFeet = int64(int16(r.Read(16))) * 4
+			a.NextWaypointETASeconds = int64(r.Read(14))
+		case 17:
+			a.AircraftICAOHex = strings.ToUpper(hex.EncodeToString(group))
+		}
+	}
+	return a
+}
+
+// 21 bit two's complement coordinate where the most significant bit is 90
+// degrees
+func ADSCCoordinate(v uint64) float64 {
+	c := int64(v)
+	if c&(1<<20) != 0 {
+		c -= 1 << 21
+	}
+	return math.Round(float64(c)*180/(1<<20)*1e5) / 1e5
+}
+
+// Reads big-endian bit fields from Data. Overrun is set if a read went past
+// the end, and zeros are returned for the missing bits.
+type BitReader struct {
+	Data    []byte
+	offset  int
+	Overrun bool
+}
+
+func (r *BitReader) Read(bits int) (v uint64) {
+	for range bits {
+		v <<= 1
+		if r.offset >= len(r.Data)*8 {
+			r.Overrun = true
+			continue
+		}
+		if r.Data[r.offset/8]&(0x80>>(r.offset%8)) != 0 {
+			v |= 1
+		}
+		r.offset++
+	}
+	return v
+}
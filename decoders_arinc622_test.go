@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// Writes big-endian bit fields, the opposite of BitReader
+type bitWriter struct {
+	data []byte
+	bits int
+}
+
+func (w *bitWriter) write(v uint64, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if v&(1<<i) != 0 {
+			w.data[len(w.data)-1] |= 0x80 >> (w.bits % 8)
+		}
+		w.bits++
+	}
+}
+
+// Returns the message text for an ARINC 622 message with payload, with the
+// CRC sent high byte first
+func arinc622Text(imi, reg string, payload []byte) string {
+	crc := ARINC622CRC([]byte(imi + reg + string(payload)))
+	return fmt.Sprintf("/AKLCDYA.%s%s%X%04X", imi, reg, payload, crc)
+}
+
+func TestARINC622CRC(t *testing.T) {
+	// The CRC-16/GENIBUS check value from the CRC catalogue
+	if crc := ARINC622CRC([]byte("123456789")); crc != 0xD64E {
+		t.Errorf("CRC of 123456789 = %04X, want D64E", crc)
+	}
+	header, payload := []byte("AT1.N8703J"), []byte{0x12, 0x34, 0x56}
+	crc := ARINC622CRC(append(header, payload...))
+	if !ARINC622CRCOK(header, payload, []byte{byte(crc >> 8), byte(crc)}) {
+		t.Error("CRC sent high byte first failed the check")
+	}
+	if ARINC622CRCOK(header, payload, []byte{byte(crc), byte(crc >> 8)}) {
+		t.Error("CRC sent low byte first passed the check")
+	}
+	if ARINC622CRCOK(header, []byte{0x12, 0x34, 0x57}, []byte{byte(crc >> 8), byte(crc)}) {
+		t.Error("corrupted payload passed the check")
+	}
+}
+
+func TestDecodeARINC622CPDLC(t *testing.T) {
+	// UM169 free text, message 5 with no reference or timestamp
+	w := bitWriter{}
+	w.write(0, 1)
+	w.write(0, 1)
+	w.write(0, 1)
+	w.write(5, 6)
+	w.write(169, 8)
+	freeText := "CONTACT ME"
+	w.write(uint64(len(freeText)-1), 8)
+	for _, c := range freeText {
+		w.write(uint64(c), 7)
+	}
+	text := arinc622Text("AT1", ".N8703J", w.data)
+
+	d, ok := decodeLabel("AA", text)
+	if !ok {
+		t.Fatalf("%s wasn't decoded", text)
+	}
+	want := CPDLCMessage{MessageID: 5, Element: "UM169", ElementText: "[freetext]", Category: "Free text", FreeText: freeText}
+	if d.Type != "CPDLC" || !d.ARINC622.CRCOK || d.ARINC622.CPDLC != want {
+		t.Errorf("decoded %+v, want CPDLC with CRC OK and %+v", d, want)
+	}
+
+	// A bit flipped in transit
+	i := len(text) - len(w.data)*2 - 4
+	data, _ := hex.DecodeString(text[i : i+2])
+	corrupted := fmt.Sprintf("%s%02X%s", text[:i], data[0]^0x01, text[i+2:])
+	if d, ok := decodeLabel("AA", corrupted); !ok || d.ARINC622.CRCOK {
+		t.Errorf("corrupted message decoded = %v with CRC OK = %v, want decoded with CRC not OK", ok, d.ARINC622.CRCOK)
+	}
+}
+
+func TestDecodeARINC622CPDLCMoreElements(t *testing.T) {
+	// DM0 WILCO, referring to message 5 at 12:34:56, with another element
+	// after it
+	w := bitWriter{}
+	w.write(1, 1)
+	w.write(1, 1)
+	w.write(1, 1)
+	w.write(9, 6)
+	w.write(5, 6)
+	w.write(12, 5)
+	w.write(34, 6)
+	w.write(56, 6)
+	w.write(0, 7)
+	w.write(0, 2)
+	w.write(3, 7)
+	d, ok := decodeLabel("BA", arinc622Text("AT1", ".N8703J", w.data))
+	if !ok {
+		t.Fatal("message wasn't decoded")
+	}
+	want := CPDLCMessage{MoreElements: true, MessageID: 9, ReferenceID: 5, Timestamp: "12:34:56", Element: "DM0", ElementText: "WILCO", Category: "Response"}
+	if !d.ARINC622.CRCOK || d.ARINC622.CPDLC != want {
+		t.Errorf("decoded %+v, want CRC OK and %+v", d.ARINC622, want)
+	}
+}
+
+func TestDecodeARINC622Malformed(t *testing.T) {
+	for _, text := range []string{
+		"/AKLCDYA.AT1.N8703J",
+		"/AKLCDYA.AT1.N8703J12",
+		"/AKLCDYA.AT1.N8703J123",
+		"/AKLCDYA.XX1.N8703J1234ABCD",
+		"AT1.N8703J1234ABCD",
+	} {
+		if d, ok := decodeLabel("AA", text); ok {
+			t.Errorf("%s decoded as %+v", text, d)
+		}
+	}
+}
+
+// Returns an ADS-C group with the tag and fields, padded to length octets.
+// Lengths are from the FANS-1/A spec rather than adscGroupLengths so a wrong
+// length there is caught.
+func adscGroup(tag byte, length int, fields ...[2]uint64) []byte {
+	w := bitWriter{}
+	for _, f := range fields {
+		w.write(f[0], int(f[1]))
+	}
+	group := make([]byte, length)
+	copy(group, w.data)
+	return append([]byte{tag}, group...)
+}
+
+// Returns a coordinate as ADS-C sends it
+func adscCoordinate(degrees float64) uint64 {
+	return uint64(int64(degrees*(1<<20)/180)) & (1<<21 - 1)
+}
+
+// Returns an altitude as ADS-C sends it, in 4 foot steps
+func adscAltitude(feet int64) uint64 {
+	return uint64(uint16(int16(feet / 4)))
+}
+
+func TestDecodeADSC(t *testing.T) {
+	basic := adscGroup(7, 10,
+		[2]uint64{adscCoordinate(45), 21},
+		[2]uint64{adscCoordinate(-90), 21},
+		[2]uint64{adscAltitude(35000), 16},
+		[2]uint64{14404, 15},
+	)
+	emergency := adscGroup(9, 10,
+		[2]uint64{adscCoordinate(-11.25), 21},
+		[2]uint64{adscCoordinate(22.5), 21},
+		[2]uint64{adscAltitude(-100), 16},
+		[2]uint64{8, 15},
+	)
+	var flightID [][2]uint64
+	for _, c := range "UAL123  " {
+		flightID = append(flightID, [2]uint64{uint64(c) & 0x3F, 6})
+	}
+	predicted := adscGroup(13, 17,
+		[2]uint64{adscCoordinate(22.5), 21},
+		[2]uint64{adscCoordinate(-45), 21},
+		[2]uint64{adscAltitude(37000), 16},
+		[2]uint64{1200, 14},
+	)
+	airframe := []byte{17, 0xA1, 0xB2, 0xC3}
+	// Earth reference, air reference and meteorological groups aren't
+	// decoded, but have to be skipped over correctly
+	earthReference := adscGroup(14, 5, [2]uint64{0x1FFF, 13}, [2]uint64{3600, 13}, [2]uint64{0xFFF, 12})
+	airReference := adscGroup(15, 5, [2]uint64{0x1FFF, 13}, [2]uint64{3280, 13}, [2]uint64{0xFFF, 12})
+	meteorological := adscGroup(16, 4, [2]uint64{0x1FF, 9}, [2]uint64{0x3FF, 10}, [2]uint64{0xFFF, 12})
+	join := func(groups ...[]byte) (b []byte) {
+		for _, g := range groups {
+			b = append(b, g...)
+		}
+		return b
+	}
+	tests := []struct {
+		name     string
+		payload  []byte
+		want     ADSCReport
+		lat, lon float64
+		altitude int64
+	}{
+		{
+			name:     "basic report",
+			payload:  basic,
+			want:     ADSCReport{ReportType: "Basic report", ReportTimeSeconds: 1800.5},
+			lat:      45,
+			lon:      -90,
+			altitude: 35000,
+		},
+		{
+			name:     "emergency basic report",
+			payload:  emergency,
+			want:     ADSCReport{ReportType: "Emergency basic report", Emergency: true, ReportTimeSeconds: 1},
+			lat:      -11.25,
+			lon:      22.5,
+			altitude: -100,
+		},
+		{
+			name:    "flight ID",
+			payload: adscGroup(12, 6, flightID...),
+			want:    ADSCReport{FlightID: "UAL123"},
+		},
+		{
+			name:    "predicted route",
+			payload: predicted,
+			want: ADSCReport{
+				NextWaypointLatitude:     22.5,
+				NextWaypointLongitude:    -45,
+				NextWaypointAltitudeFeet: 37000,
+				NextWaypointETASeconds:   1200,
+			},
+		},
+		{
+			name:    "every group",
+			payload: join(basic, adscGroup(12, 6, flightID...), predicted, earthReference, airReference, meteorological, airframe),
+			want: ADSCReport{
+				ReportType:               "Basic report",
+				ReportTimeSeconds:        1800.5,
+				FlightID:                 "UAL123",
+				AircraftICAOHex:          "A1B2C3",
+				NextWaypointLatitude:     22.5,
+				NextWaypointLongitude:    -45,
+				NextWaypointAltitudeFeet: 37000,
+				NextWaypointETASeconds:   1200,
+			},
+			lat:      45,
+			lon:      -90,
+			altitude: 35000,
+		},
+		{
+			name:    "truncated basic report",
+			payload: basic[:6],
+		},
+		{
+			name:     "truncated after basic report",
+			payload:  join(basic, predicted[:10]),
+			want:     ADSCReport{ReportType: "Basic report", ReportTimeSeconds: 1800.5},
+			lat:      45,
+			lon:      -90,
+			altitude: 35000,
+		},
+		{
+			name:    "unknown group",
+			payload: join([]byte{0xFF, 0x00}, basic),
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d DecodedMessage
+			if got := DecodeADSC(tt.payload, &d); got != tt.want {
+				t.Errorf("DecodeADSC() = %+v, want %+v", got, tt.want)
+			}
+			if d.Latitude != tt.lat || d.Longitude != tt.lon || d.AltitudeFeet != tt.altitude {
+				t.Errorf("position %v,%v at %d ft, want %v,%v at %d ft", d.Latitude, d.Longitude, d.AltitudeFeet, tt.lat, tt.lon, tt.altitude)
+			}
+		})
+	}
+}
+
+func TestDecodeARINC622ADSC(t *testing.T) {
+	payload := adscGroup(7, 10,
+		[2]uint64{adscCoordinate(45), 21},
+		[2]uint64{adscCoordinate(-90), 21},
+		[2]uint64{adscAltitude(35000), 16},
+		[2]uint64{14404, 15},
+	)
+	d, ok := decodeLabel("B6", arinc622Text("ADS", ".N8703J", payload))
+	if !ok {
+		t.Fatal("message wasn't decoded")
+	}
+	if d.Type != "ADS-C" || !d.ARINC622.CRCOK || d.ARINC622.ADSC.ReportType != "Basic report" {
+		t.Errorf("decoded %+v, want an ADS-C basic report with CRC OK", d)
+	}
+	if d.Latitude != 45 || d.Longitude != -90 || d.AltitudeFeet != 35000 {
+		t.Errorf("position %v,%v at %d ft, want 45,-90 at 35000 ft", d.Latitude, d.Longitude, d.AltitudeFeet)
+	}
+
+	// Contract requests from the ground aren't decoded
+	d, ok = decodeLabel("A6", arinc622Text("ADS", ".N8703J", payload))
+	if !ok || d.ARINC622.ADSC != (ADSCReport{ReportType: "Contract request"}) {
+		t.Errorf("uplink decoded = %v as %+v, want a contract request", ok, d.ARINC622.ADSC)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+// A range of CPDLC message elements and what they're for
+type CPDLCElementCategory struct {
+	First, Last int
+	Category    string
+}
+
+// Categories of FANS-1/A uplink elements, to filter on things like reroutes
+// without listing every element
+var CPDLCUplinkCategories = []CPDLCElementCategory{
+	{0, 5, "Response"},
+	{6, 41, "Vertical clearance"},
+	{42, 63, "Crossing constraint"},
+	{64, 72, "Lateral offset"},
+	{73, 99, "Route modification"},
+	{100, 116, "Speed change"},
+	{117, 122, "Contact"},
+	{123, 126, "Surveillance"},
+	{127, 147, "Report request"},
+	{148, 152, "Negotiation request"},
+	{153, 156, "Advisory"},
+	{157, 163, "System management"},
+	{164, 168, "Additional"},
+	{169, 170, "Free text"},
+	{171, 174, "Vertical clearance"},
+	{175, 175, "Report request"},
+	{176, 177, "Additional"},
+	{178, 178, "Route modification"},
+	{179, 179, "Surveillance"},
+	{180, 182, "Report request"},
+}
+
+// Categories of FANS-1/A downlink elements
+var CPDLCDownlinkCategories = []CPDLCElementCategory{
+	{0, 5, "Response"},
+	{6, 14, "Vertical request"},
+	{15, 17, "Lateral offset request"},
+	{18, 19, "Speed request"},
+	{20, 21, "Voice contact request"},
+	{22, 27, "Route modification request"},
+	{28, 48, "Report"},
+	{49, 54, "Negotiation request"},
+	{55, 61, "Emergency"},
+	{62, 64, "System management"},
+	{65, 66, "Additional"},
+	{67, 68, "Free text"},
+	{69, 69, "Vertical request"},
+	{70, 71, "Route modification request"},
+	{72, 72, "Report"},
+	{73, 73, "System management"},
+	{74, 75, "Additional"},
+	{76, 79, "Report"},
+	{80, 80, "Emergency"},
+}
+
+// Returns the category of a message element, empty if it isn't in one
+func CPDLCCategory(categories []CPDLCElementCategory, element int) string {
+	for _, c := range categories {
+		if element >= c.First && element <= c.Last {
+			return c.Category
+		}
+	}
+	return ""
+}
+
+// FANS-1/A uplink message elements (UM0 to UM182) with their parameters in
+// brackets
+var CPDLCUplinkElements = []string{
+	"UNABLE",
+	"STANDBY",
+	"REQUEST DEFERRED",
+	"ROGER",
+	"AFFIRM",
+	"NEGATIVE",
+	"EXPECT [altitude]",
+	"EXPECT CLIMB AT [time]",
+	"EXPECT CLIMB AT [position]",
+	"EXPECT DESCENT AT [time]",
+	"EXPECT DESCENT AT [position]",
+	"EXPECT CRUISE CLIMB AT [time]",
+	"EXPECT CRUISE CLIMB AT [position]",
+	"AT [time] EXPECT CLIMB TO [altitude]",
+	"AT [position] EXPECT CLIMB TO [altitude]",
+	"AT [time] EXPECT DESCENT TO [altitude]",
+	"AT [position] EXPECT DESCENT TO [altitude]",
+	"AT [time] EXPECT CRUISE CLIMB TO [altitude]",
+	"AT [position] EXPECT CRUISE CLIMB TO [altitude]",
+	"MAINTAIN [altitude]",
+	"CLIMB TO AND MAINTAIN [altitude]",
+	"AT [time] CLIMB TO AND MAINTAIN [altitude]",
+	"AT [position] CLIMB TO AND MAINTAIN [altitude]",
+	"DESCEND TO AND MAINTAIN [altitude]",
+	"AT [time] DESCEND TO AND MAINTAIN [altitude]",
+	"AT [position] DESCEND TO AND MAINTAIN [altitude]",
+	"CLIMB TO REACH [altitude] BY [time]",
+	"CLIMB TO REACH [altitude] BY [position]",
+	"DESCEND TO REACH [altitude] BY [time]",
+	"DESCEND TO REACH [altitude] BY [position]",
+	"MAINTAIN BLOCK [altitude] TO [altitude]",
+	"CLIMB TO AND MAINTAIN BLOCK [altitude] TO [altitude]",
+	"DESCEND TO AND MAINTAIN BLOCK [altitude] TO [altitude]",
+	"CRUISE [altitude]",
+	"CRUISE CLIMB TO [altitude]",
+	"CRUISE CLIMB ABOVE [altitude]",
+	"EXPEDITE CLIMB TO [altitude]",
+	"EXPEDITE DESCENT TO [altitude]",
+	"IMMEDIATELY CLIMB TO [altitude]",
+	"IMMEDIATELY DESCEND TO [altitude]",
+	"IMMEDIATELY STOP CLIMB AT [altitude]",
+	"IMMEDIATELY STOP DESCENT AT [altitude]",
+	"EXPECT TO CROSS [position] AT [altitude]",
+	"EXPECT TO CROSS [position] AT OR ABOVE [altitude]",
+	"EXPECT TO CROSS [position] AT OR BELOW [altitude]",
+	"EXPECT TO CROSS [position] AT AND MAINTAIN [altitude]",
+	"CROSS [position] AT [altitude]",
+	"CROSS [position] AT OR ABOVE [altitude]",
+	"CROSS [position] AT OR BELOW [altitude]",
+	"CROSS [position] AT AND MAINTAIN [altitude]",
+	"CROSS [position] BETWEEN [altitude] AND [altitude]",
+	"CROSS [position] AT [time]",
+	"CROSS [position] AT OR BEFORE [time]",
+	"CROSS [position] AT OR AFTER [time]",
+	"CROSS [position] BETWEEN [time] AND [time]",
+	"CROSS [position] AT [speed]",
+	"CROSS [position] AT OR LESS THAN [speed]",
+	"CROSS [position] AT OR GREATER THAN [speed]",
+	"CROSS [position] AT [time] AT [altitude]",
+	"CROSS [position] AT OR BEFORE [time] AT [altitude]",
+	"CROSS [position] AT OR AFTER [time] AT [altitude]",
+	"CROSS [position] AT AND MAINTAIN [altitude] AT [speed]",
+	"AT [time] CROSS [position] AT AND MAINTAIN [altitude]",
+	"AT [time] CROSS [position] AT AND MAINTAIN [altitude] AT [speed]",
+	"OFFSET [distanceoffset] [direction] OF ROUTE",
+	"AT [position] OFFSET [distanceoffset] [direction] OF ROUTE",
+	"AT [time] OFFSET [distanceoffset] [direction] OF ROUTE",
+	"PROCEED BACK ON ROUTE",
+	"REJOIN ROUTE BY [position]",
+	"REJOIN ROUTE BY [time]",
+	"EXPECT BACK ON ROUTE BY [position]",
+	"EXPECT BACK ON ROUTE BY [time]",
+	"RESUME OWN NAVIGATION",
+	"[predepartureclearance]",
+	"PROCEED DIRECT TO [position]",
+	"WHEN ABLE PROCEED DIRECT TO [position]",
+	"AT [time] PROCEED DIRECT TO [position]",
+	"AT [position] PROCEED DIRECT TO [position]",
+	"AT [altitude] PROCEED DIRECT TO [position]",
+	"CLEARED TO [position] VIA [routeclearance]",
+	"CLEARED [routeclearance]",
+	"CLEARED [procedurename]",
+	"CLEARED TO DEVIATE UP TO [distanceoffset] [direction] OF ROUTE",
+	"AT [position] CLEARED [routeclearance]",
+	"AT [position] CLEARED [procedurename]",
+	"EXPECT [routeclearance]",
+	"AT [position] EXPECT [routeclearance]",
+	"EXPECT DIRECT TO [position]",
+	"AT [position] EXPECT DIRECT TO [position]",
+	"AT [time] EXPECT DIRECT TO [position]",
+	"AT [altitude] EXPECT DIRECT TO [position]",
+	"HOLD AT [position] MAINTAIN [altitude] INBOUND TRACK [degrees] [direction] TURN LEG TIME [legtype]",
+	"HOLD AT [position] AS PUBLISHED MAINTAIN [altitude]",
+	"EXPECT FURTHER CLEARANCE AT [time]",
+	"TURN [direction] HEADING [degrees]",
+	"TURN [direction] GROUND TRACK [degrees]",
+	"FLY PRESENT HEADING",
+	"AT [position] FLY HEADING [degrees]",
+	"IMMEDIATELY TURN [direction] HEADING [degrees]",
+	"EXPECT [procedurename]",
+	"AT [time] EXPECT [speed]",
+	"AT [position] EXPECT [speed]",
+	"AT [altitude] EXPECT [speed]",
+	"AT [time] EXPECT [speed] TO [speed]",
+	"AT [position] EXPECT [speed] TO [speed]",
+	"AT [altitude] EXPECT [speed] TO [speed]",
+	"MAINTAIN [speed]",
+	"MAINTAIN PRESENT SPEED",
+	"MAINTAIN [speed] OR GREATER",
+	"MAINTAIN [speed] OR LESS",
+	"MAINTAIN [speed] TO [speed]",
+	"INCREASE SPEED TO [speed]",
+	"INCREASE SPEED TO [speed] OR GREATER",
+	"REDUCE SPEED TO [speed]",
+	"REDUCE SPEED TO [speed] OR LESS",
+	"DO NOT EXCEED [speed]",
+	"RESUME NORMAL SPEED",
+	"CONTACT [icaounitname] [frequency]",
+	"AT [position] CONTACT [icaounitname] [frequency]",
+	"AT [time] CONTACT [icaounitname] [frequency]",
+	"MONITOR [icaounitname] [frequency]",
+	"AT [position] MONITOR [icaounitname] [frequency]",
+	"AT [time] MONITOR [icaounitname] [frequency]",
+	"SQUAWK [beaconcode]",
+	"STOP SQUAWK",
+	"SQUAWK ALTITUDE",
+	"STOP ALTITUDE SQUAWK",
+	"REPORT BACK ON ROUTE",
+	"REPORT LEAVING [altitude]",
+	"REPORT LEVEL [altitude]",
+	"REPORT PASSING [position]",
+	"REPORT REMAINING FUEL AND SOULS ON BOARD",
+	"CONFIRM POSITION",
+	"CONFIRM ALTITUDE",
+	"CONFIRM SPEED",
+	"CONFIRM ASSIGNED ALTITUDE",
+	"CONFIRM ASSIGNED SPEED",
+	"CONFIRM ASSIGNED ROUTE",
+	"CONFIRM TIME OVER REPORTED WAYPOINT",
+	"CONFIRM REPORTED WAYPOINT",
+	"CONFIRM NEXT WAYPOINT",
+	"CONFIRM NEXT WAYPOINT ETA",
+	"CONFIRM ENSUING WAYPOINT",
+	"CONFIRM REQUEST",
+	"CONFIRM SQUAWK",
+	"CONFIRM HEADING",
+	"CONFIRM GROUND TRACK",
+	"REQUEST POSITION REPORT",
+	"WHEN CAN YOU ACCEPT [altitude]",
+	"CAN YOU ACCEPT [altitude] AT [position]",
+	"CAN YOU ACCEPT [altitude] AT [time]",
+	"WHEN CAN YOU ACCEPT [speed]",
+	"WHEN CAN YOU ACCEPT [distanceoffset] [direction] OFFSET",
+	"ALTIMETER [altimeter]",
+	"RADAR SERVICES TERMINATED",
+	"RADAR CONTACT [position]",
+	"RADAR CONTACT LOST",
+	"CHECK STUCK MICROPHONE [frequency]",
+	"ATIS [atiscode]",
+	"ERROR [errorinformation]",
+	"NEXT DATA AUTHORITY [icaofacilitydesignation]",
+	"END SERVICE",
+	"SERVICE UNAVAILABLE",
+	"[icaofacilitydesignation] [tp4table]",
+	"WHEN READY",
+	"THEN",
+	"DUE TO TRAFFIC",
+	"DUE TO AIRSPACE RESTRICTION",
+	"DISREGARD",
+	"[freetext]",
+	"[freetext]",
+	"CLIMB AT [verticalrate] MINIMUM",
+	"CLIMB AT [verticalrate] MAXIMUM",
+	"DESCEND AT [verticalrate] MINIMUM",
+	"DESCEND AT [verticalrate] MAXIMUM",
+	"REPORT REACHING [altitude]",
+	"MAINTAIN OWN SEPARATION AND VMC",
+	"AT PILOTS DISCRETION",
+	"[trackdetailmsg]",
+	"SQUAWK IDENT",
+	"REPORT REACHING BLOCK [altitude] TO [altitude]",
+	"REPORT DISTANCE [tofrom] [position]",
+	"CONFIRM ATIS CODE",
+}
+
+// FANS-1/A downlink message elements (DM0 to DM80) with their parameters in
+// brackets
+var CPDLCDownlinkElements = []string{
+	"WILCO",
+	"UNABLE",
+	"STANDBY",
+	"ROGER",
+	"AFFIRM",
+	"NEGATIVE",
+	"REQUEST [altitude]",
+	"REQUEST BLOCK [altitude] TO [altitude]",
+	"REQUEST CRUISE CLIMB TO [altitude]",
+	"REQUEST CLIMB TO [altitude]",
+	"REQUEST DESCENT TO [altitude]",
+	"AT [position] REQUEST CLIMB TO [altitude]",
+	"AT [position] REQUEST DESCENT TO [altitude]",
+	"AT [time] REQUEST CLIMB TO [altitude]",
+	"AT [time] REQUEST DESCENT TO [altitude]",
+	"REQUEST OFFSET [distanceoffset] [direction] OF ROUTE",
+	"AT [position] REQUEST OFFSET [distanceoffset] [direction] OF ROUTE",
+	"AT [time] REQUEST OFFSET [distanceoffset] [direction] OF ROUTE",
+	"REQUEST [speed]",
+	"REQUEST [speed] TO [speed]",
+	"REQUEST VOICE CONTACT",
+	"REQUEST VOICE CONTACT [frequency]",
+	"REQUEST DIRECT TO [position]",
+	"REQUEST [procedurename]",
+	"REQUEST [routeclearance]",
+	"REQUEST CLEARANCE",
+	"REQUEST WEATHER DEVIATION TO [position] VIA [routeclearance]",
+	"REQUEST WEATHER DEVIATION UP TO [distanceoffset] [direction] OF ROUTE",
+	"LEAVING [altitude]",
+	"CLIMBING TO [altitude]",
+	"DESCENDING TO [altitude]",
+	"PASSING [position]",
+	"PRESENT ALTITUDE [altitude]",
+	"PRESENT POSITION [position]",
+	"PRESENT SPEED [speed]",
+	"PRESENT HEADING [degrees]",
+	"PRESENT GROUND TRACK [degrees]",
+	"LEVEL [altitude]",
+	"ASSIGNED ALTITUDE [altitude]",
+	"ASSIGNED SPEED [speed]",
+	"ASSIGNED ROUTE [routeclearance]",
+	"BACK ON ROUTE",
+	"NEXT WAYPOINT [position]",
+	"NEXT WAYPOINT ETA [time]",
+	"ENSUING WAYPOINT [position]",
+	"REPORTED WAYPOINT [position]",
+	"REPORTED WAYPOINT [time]",
+	"SQUAWKING [beaconcode]",
+	"POSITION REPORT [positionreport]",
+	"WHEN CAN WE EXPECT [speed]",
+	"WHEN CAN WE EXPECT [speed] TO [speed]",
+	"WHEN CAN WE EXPECT BACK ON ROUTE",
+	"WHEN CAN WE EXPECT LOWER ALTITUDE",
+	"WHEN CAN WE EXPECT HIGHER ALTITUDE",
+	"WHEN CAN WE EXPECT CRUISE CLIMB TO [altitude]",
+	"PAN PAN PAN",
+	"MAYDAY MAYDAY MAYDAY",
+	"[remainingfuel] OF FUEL REMAINING AND [remainingsouls] SOULS ON BOARD",
+	"CANCEL EMERGENCY",
+	"DIVERTING TO [position] VIA [routeclearance]",
+	"OFFSETTING [distanceoffset] [direction] OF ROUTE",
+	"DESCENDING TO [altitude]",
+	"ERROR [errorinformation]",
+	"NOT CURRENT DATA AUTHORITY",
+	"[icaofacilitydesignation]",
+	"DUE TO WEATHER",
+	"DUE TO AIRCRAFT PERFORMANCE",
+	"[freetext]",
+	"[freetext]",
+	"REQUEST VMC DESCENT",
+	"REQUEST HEADING [degrees]",
+	"REQUEST GROUND TRACK [degrees]",
+	"REACHING [altitude]",
+	"[versionnumber]",
+	"MAINTAIN OWN SEPARATION AND VMC",
+	"AT PILOTS DISCRETION",
+	"REACHING BLOCK [altitude] TO [altitude]",
+	"ASSIGNED BLOCK [altitude] TO [altitude]",
+	"AT [time] [distance] [tofrom] [position]",
+	"ATIS [atiscode]",
+	"DEVIATING [distanceoffset] [direction] OF ROUTE",
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Origin, destination and time, like KPHXKLAX0820
+	oooiRegex = regexp.MustCompile(`^([A-Z]{4})([A-Z]{4})(\d{4})`)
+	// N 44.203,W 86.546,31965
+	decimalPositionRegex = regexp.MustCompile(`^([NS] ?\d{1,2}\.\d+),\s*([EW] ?\d{1,3}\.\d+),\s*(\d+)`)
+	// N38123W121456 or N 38.123W121.456
+	positionRegex = regexp.MustCompile(`^([NS] ?[\d.]+)\s*([EW] ?[\d.]+)$`)
+	// Preambles H1 messages start with before the actual content
+	h1PreambleRegex = regexp.MustCompile(`^(- )?#(M\d[A-Z]|DF[AB])`)
+	// Two ICAO airport codes, like KSFOKORD or KSFO KORD
+	airportPairRegex = regexp.MustCompile(`^([A-Z]{4}) ?([A-Z]{4})\b`)
+	// United's airline ops messages start with a slash and a two letter code
+	airlineOpsRegex = regexp.MustCompile(`^/([A-Z0-9]{2})\b ?(.*)$`)
+)
+
+// Kinds of 5Z messages
+var AirlineOpsTypes = map[string]string{
+	"B1": "Request Weight and Balance",
+	"B3": "Request Departure Clearance",
+	"CD": "Weight and Balance",
+	"CG": "Request Pre-Departure Clearance",
+	"CM": "Crew Scheduling",
+	"C3": "Off Schedule Notification",
+	"C4": "Flight Dispatch",
+	"C5": "Maintenance Message",
+	"C6": "Customer Service",
+	"DS": "Late Message",
+	"D3": "Holding Pattern Message",
+	"D6": "From-To and Date",
+	"D7": "From-To, Alternate and Time",
+	"EO": "In Range",
+	"ET": "Expected Time of Arrival",
+	"PW": "Position Weather",
+	"RL": "Request Release",
+	"R3": "Request HOWGOZIT Message",
+	"R4": "Request Latest POSBD",
+	"TC": "From-To Fuel",
+	"WB": "From-To",
+	"W1": "Request Weather for City",
+}
+
+// Returns a decoder for the OOOI (out, off, on, in) label with the given
+// event
+func DecodeOOOI(event string) LabelDecoder {
+	return func(text string) (d DecodedMessage, ok bool) {
+		m := oooiRegex.FindStringSubmatch(text)
+		if m == nil || !ValidHHMM(m[3]) {
+			return d, false
+		}
+		d = DecodedMessage{Type: "OOOI", Subtype: event, Origin: m[1], Destination: m[2]}
+		switch event {
+		case "OUT":
+			d.OutTime = m[3]
+		case "OFF":
+			d.OffTime = m[3]
+		case "ON":
+			d.OnTime = m[3]
+		default:
+			d.InTime = m[3]
+		}
+		return d, true
+	}
+}
+
+// Decodes label 16 position reports, like N 44.203,W 86.546,31965,6, 290
+func DecodeDecimalPositionReport(text string) (d DecodedMessage, ok bool) {
+	m := decimalPositionRegex.FindStringSubmatch(text)
+	if m == nil {
+		return d, false
+	}
+	lat, latOK := ParseCoordinate(m[1])
+	lon, lonOK := ParseCoordinate(m[2])
+	alt, altOK := ParseAltitude(m[3])
+	if !latOK || !lonOK || !altOK {
+		return d, false
+	}
+	return DecodedMessage{Type: "Position", Latitude: lat, Longitude: lon, AltitudeFeet: alt}, true
+}
+
+// Decodes ARINC 620 position reports, like
+// POSN42321W083351,SAVOY,211841,350,DUNNK,212011,...
+func DecodePOSReport(text string) (d DecodedMessage, ok bool) {
+	if !strings.HasPrefix(text, "POS") {
+		return d, false
+	}
+	fields := strings.Split(strings.TrimPrefix(text, "POS"), ",")
+	m := positionRegex.FindStringSubmatch(strings.TrimSpace(fields[0]))
+	if m == nil {
+		return d, false
+	}
+	lat, latOK := ParseCoordinate(m[1])
+	lon, lonOK := ParseCoordinate(m[2])
+	if !latOK || !lonOK {
+		return d, false
+	}
+	d = DecodedMessage{Type: "Position", Latitude: lat, Longitude: lon}
+	field := func(i int) string {
+		if i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	d.Waypoint = field(1)
+	if alt, ok := ParseAltitude(field(3)); ok {
+		d.AltitudeFeet = alt
+	}
+	d.NextWaypoint = field(4)
+	// Times are HHMMSS
+	if eta := field(5); len(eta) >= 4 && ValidHHMM(eta[:4]) {
+		d.ETA = eta[:4]
+	}
+	return d, true
+}
+
+// Returns the text of an H1 message without its preamble, ok is false if it
+// didn't have one
+func TrimH1Preamble(text string) (string, bool) {
+	loc := h1PreambleRegex.FindStringIndex(text)
+	if loc == nil {
+		return text, false
+	}
+	return text[loc[1]:], true
+}
+
+// Decodes H1 position reports, like #M1BPOSN42321W083351,SAVOY,...
+func DecodeH1PositionReport(text string) (d DecodedMessage, ok bool) {
+	text, _ = TrimH1Preamble(text)
+	return DecodePOSReport(text)
+}
+
+// Decodes H1 flight plans, like
+// #M1BFPN/RI:DA:KEWR:AA:KDFW:CR:EWRDFW01:F:N38.53W077.55..VIPPS.J211
+func DecodeH1FlightPlan(text string) (d DecodedMessage, ok bool) {
+	text, _ = TrimH1Preamble(text)
+	i := strings.Index(text, "FPN/")
+	if i < 0 {
+		return d, false
+	}
+	parts := strings.Split(text[i+len("FPN/"):], ":")
+	d = DecodedMessage{Type: "FlightPlan"}
+	for i := 0; i+1 < len(parts); i++ {
+		switch parts[i] {
+		case "DA":
+			d.Origin = parts[i+1]
+		case "AA":
+			d.Destination = parts[i+1]
+		case "F":
+			// The route is the rest of the message
+			d.Route = strings.TrimSpace(strings.Join(parts[i+1:], ":"))
+			i = len(parts)
+		}
+	}
+	if d.Origin == "" && d.Destination == "" && d.Route == "" {
+		return d, false
+	}
+	return d, true
+}
+
+// Decodes United's label 5Z airline operations messages, like
+// /WB KSFOKORD
+func DecodeAirlineOps(text string) (d DecodedMessage, ok bool) {
+	m := airlineOpsRegex.FindStringSubmatch(text)
+	if m == nil {
+		return d, false
+	}
+	description, known := AirlineOpsTypes[m[1]]
+	if !known {
+		return d, false
+	}
+	d = DecodedMessage{Type: "AirlineOps", Subtype: m[1], SubtypeDescription: description}
+	if ap := airportPairRegex.FindStringSubmatch(m[2]); ap != nil && strings.HasPrefix(description, "From-To") {
+		d.Origin, d.Destination = ap[1], ap[2]
+	}
+	return d, true
+}
+
+// Returns true if s is a valid 24 hour HHMM time
+func ValidHHMM(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s[:2] < "24" && s[2:] < "60"
+}
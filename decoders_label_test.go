@@ -0,0 +1,169 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Runs the decoders for a label the way DecoderAnnotator does
+func decodeLabel(label, text string) (d DecodedMessage, ok bool) {
+	for _, decode := range LabelDecoders[label] {
+		if d, ok = decode(text); ok {
+			return d, true
+		}
+	}
+	return d, false
+}
+
+func TestLabelDecoders(t *testing.T) {
+	tests := []struct {
+		name  string
+		label string
+		text  string
+		ok    bool
+		want  DecodedMessage
+	}{
+		{
+			name:  "QP out",
+			label: "QP",
+			text:  "KSFOKLAX0901",
+			ok:    true,
+			want:  DecodedMessage{Type: "OOOI", Subtype: "OUT", Origin: "KSFO", Destination: "KLAX", OutTime: "0901"},
+		},
+		{
+			name:  "QQ off",
+			label: "QQ",
+			text:  "KPHXKLAX0820",
+			ok:    true,
+			want:  DecodedMessage{Type: "OOOI", Subtype: "OFF", Origin: "KPHX", Destination: "KLAX", OffTime: "0820"},
+		},
+		{
+			name:  "QR on with trailing fuel",
+			label: "QR",
+			text:  "KDENKORD1523 0123",
+			ok:    true,
+			want:  DecodedMessage{Type: "OOOI", Subtype: "ON", Origin: "KDEN", Destination: "KORD", OnTime: "1523"},
+		},
+		{
+			name:  "QS in",
+			label: "QS",
+			text:  "KJFKEGLL0644",
+			ok:    true,
+			want:  DecodedMessage{Type: "OOOI", Subtype: "IN", Origin: "KJFK", Destination: "EGLL", InTime: "0644"},
+		},
+		{
+			name:  "QT return in",
+			label: "QT",
+			text:  "KATLKATL1302",
+			ok:    true,
+			want:  DecodedMessage{Type: "OOOI", Subtype: "RETURN IN", Origin: "KATL", Destination: "KATL", InTime: "1302"},
+		},
+		{name: "QQ truncated time", label: "QQ", text: "KPHXKLAX08"},
+		{name: "QQ truncated airports", label: "QQ", text: "KPHXKL"},
+		{name: "QQ invalid time", label: "QQ", text: "KPHXKLAX2599"},
+		{name: "QQ lowercase", label: "QQ", text: "kphxklax0820"},
+		{
+			name:  "16 decimal position",
+			label: "16",
+			text:  "N 44.203,W 86.546,31965,6, 290",
+			ok:    true,
+			want:  DecodedMessage{Type: "Position", Latitude: 44.203, Longitude: -86.546, AltitudeFeet: 31965},
+		},
+		{
+			name:  "16 flight level",
+			label: "16",
+			text:  "S 33.947,E151.179,350",
+			ok:    true,
+			want:  DecodedMessage{Type: "Position", Latitude: -33.947, Longitude: 151.179, AltitudeFeet: 35000},
+		},
+		{name: "16 truncated", label: "16", text: "N 44.203,W 86.546,"},
+		{name: "16 latitude out of range", label: "16", text: "N 94.203,W 86.546,31965"},
+		{name: "16 longitude out of range", label: "16", text: "N 44.203,W186.546,31965"},
+		{
+			name:  "20 position report",
+			label: "20",
+			text:  "POSN42321W083351,SAVOY,211841,350,DUNNK,212011,M52,27010,218",
+			ok:    true,
+			want:  DecodedMessage{Type: "Position", Latitude: 42.535, Longitude: -83.585, AltitudeFeet: 35000, Waypoint: "SAVOY", NextWaypoint: "DUNNK", ETA: "2120"},
+		},
+		{
+			name:  "20 position only",
+			label: "20",
+			text:  "POSN42321W083351",
+			ok:    true,
+			want:  DecodedMessage{Type: "Position", Latitude: 42.535, Longitude: -83.585},
+		},
+		{name: "20 truncated position", label: "20", text: "POSN42321W08"},
+		{name: "20 no position", label: "20", text: "POS"},
+		{name: "20 minutes out of range", label: "20", text: "POSN42751W083351,SAVOY"},
+		{name: "20 not a position report", label: "20", text: "REQ POS"},
+		{
+			name:  "H1 M1B flight plan",
+			label: "H1",
+			text:  "#M1BFPN/RI:DA:KEWR:AA:KDFW:CR:EWRDFW01:F:N38.53W077.55..VIPPS.J211",
+			ok:    true,
+			want:  DecodedMessage{Type: "FlightPlan", Origin: "KEWR", Destination: "KDFW", Route: "N38.53W077.55..VIPPS.J211"},
+		},
+		{
+			name:  "H1 DFB flight plan with dash",
+			label: "H1",
+			text:  "- #DFBFPN/RI:DA:EGLL:AA:KJFK",
+			ok:    true,
+			want:  DecodedMessage{Type: "FlightPlan", Origin: "EGLL", Destination: "KJFK"},
+		},
+		{
+			name:  "H1 M1B position report",
+			label: "H1",
+			text:  "#M1BPOSN42321W083351,SAVOY,211841,350,DUNNK,212011,M52,27010,218",
+			ok:    true,
+			want:  DecodedMessage{Type: "Position", Latitude: 42.535, Longitude: -83.585, AltitudeFeet: 35000, Waypoint: "SAVOY", NextWaypoint: "DUNNK", ETA: "2120"},
+		},
+		{name: "H1 truncated flight plan", label: "H1", text: "#M1BFPN/RI"},
+		{name: "H1 empty flight plan fields", label: "H1", text: "#M1BFPN/RI:DA:"},
+		{name: "H1 preamble only", label: "H1", text: "#M1B"},
+		{name: "H1 truncated position", label: "H1", text: "#DFBPOSN423"},
+		{
+			name:  "5Z from-to",
+			label: "5Z",
+			text:  "/WB KSFOKORD",
+			ok:    true,
+			want:  DecodedMessage{Type: "AirlineOps", Subtype: "WB", SubtypeDescription: "From-To", Origin: "KSFO", Destination: "KORD"},
+		},
+		{
+			name:  "5Z from-to with a space",
+			label: "5Z",
+			text:  "/D6 KIAH KEWR 16",
+			ok:    true,
+			want:  DecodedMessage{Type: "AirlineOps", Subtype: "D6", SubtypeDescription: "From-To and Date", Origin: "KIAH", Destination: "KEWR"},
+		},
+		{
+			name:  "5Z request without airports",
+			label: "5Z",
+			text:  "/B3 REQUEST DEP CLX",
+			ok:    true,
+			want:  DecodedMessage{Type: "AirlineOps", Subtype: "B3", SubtypeDescription: "Request Departure Clearance"},
+		},
+		{name: "5Z unknown type", label: "5Z", text: "/ZZ KSFOKORD"},
+		{name: "5Z no slash", label: "5Z", text: "WB KSFOKORD"},
+		{name: "5Z truncated", label: "5Z", text: "/W"},
+		{name: "label without decoders", label: "_d", text: "KPHXKLAX0820"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := decodeLabel(tt.label, tt.text)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (decoded %+v)", ok, tt.ok, got)
+			}
+			if !ok {
+				return
+			}
+			if math.Abs(got.Latitude-tt.want.Latitude) > 1e-4 || math.Abs(got.Longitude-tt.want.Longitude) > 1e-4 {
+				t.Errorf("position = %f,%f, want %f,%f", got.Latitude, got.Longitude, tt.want.Latitude, tt.want.Longitude)
+			}
+			got.Latitude, got.Longitude = tt.want.Latitude, tt.want.Longitude
+			if got != tt.want {
+				t.Errorf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
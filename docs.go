@@ -30,13 +30,23 @@ var (
 > distances.
 
 `
-	sourceDoc         = "## Sources\n\n### ACARS Messages\n\n- %s\n\n### VDLM2 Messages\n\n- %s\n\n"
+	sourceDoc         = "## Sources\n\n### ACARS Messages\n\n- %s\n\n### VDLM2 Messages\n\n- %s\n\n### HFDL Messages\n\n- %s\n\n### SATCOM Messages\n\n- %s\n\n"
+	stagesDoc         = "## Pipeline Stages\n\nThese are added by stages configured in `ACARSProcessorSettings` before any steps run.\n\n### Reassembly\n\n- %s\n\n### Deduplication\n\n- %s\n\n### Threading\n\n- %s\n\n"
 	fieldsDocPath     = "default_fields.md"
 	exampleConfigPath = "config_all_options.yaml"
 	Annotators = []Annotator{
 		AnnotateStep{}.ADSB,
 		AnnotateStep{}.Ollama,
 		AnnotateStep{}.Tar1090,
+		AnnotateStep{}.Decoder,
+		AnnotateStep{}.Label,
+		AnnotateStep{}.Airline,
+		AnnotateStep{}.AircraftDatabase,
+		AnnotateStep{}.Readsb,
+		AnnotateStep{}.Airport,
+		AnnotateStep{}.Weather,
+		AnnotateStep{}.Clearance,
+		AnnotateStep{}.Planespotters,
 	}
 )
 
@@ -63,6 +73,28 @@ func GenerateDocs() (updated bool) {
 		Name:  "num_predict",
 		Value: 512,
 	}}
+	// We need to do this to set an example value since Labels is a map.
+	defaultConfig.Steps[0].Annotate.Label.Labels = map[string]LabelDefinition{
+		"H1": {Description: "Message to or from an onboard terminal", Category: "Operations", Sublabels: map[string]LabelDefinition{
+			"M1": {Description: "FMC message", Category: "Position"},
+		}},
+	}
+	// We need to do this to set an example value since Stations is a map.
+	defaultConfig.Stations = map[string]StationConfig{
+		"my-station": {Name: "Haneda", Geolocation: "35.6244416,139.7753782", ElevationMeters: 10},
+	}
+	// We need to do this to set an example value since Zones is a slice.
+	defaultConfig.Steps[0].Filter.Geofence.Zones = []GeofenceZone{{
+		Name: "Final approach",
+		Polygons: [][]string{{
+			"35.55,139.78",
+			"35.55,139.90",
+			"35.62,139.90",
+			"35.62,139.78",
+		}},
+		MaximumAltitudeFeet: 3000,
+	}}
+	defaultConfig.Steps[0].Filter.Geofence.GeoJSONFiles = []string{"./county.geojson"}
 	defaultConfig.Steps[0].Send.Discord.EmbedColorGradientSteps = []hue.Color{
 		{R: 0, G: 255, B: 0},
 		{R: 255, G: 255, B: 0},
@@ -73,6 +105,9 @@ func GenerateDocs() (updated bool) {
 	ac := &defaultConfig.ACARSProcessorSettings.ACARSHub
 	ac.ACARS.SelectedFields = ac.ACARS.GetDefaultFields()
 	ac.VDLM2.SelectedFields = ac.VDLM2.GetDefaultFields()
+	ac.HFDL.SelectedFields = ac.HFDL.GetDefaultFields()
+	sc := &defaultConfig.ACARSProcessorSettings.Listen.SATCOM
+	sc.SelectedFields = sc.GetDefaultFields()
 
 	// You can also select them on Annotators
 	a := &defaultConfig.Steps[0].Annotate.ADSB
@@ -81,6 +116,24 @@ func GenerateDocs() (updated bool) {
 	a.SelectedFields = a.GetDefaultFields()
 	o.SelectedFields = o.GetDefaultFields()
 	t.SelectedFields = t.GetDefaultFields()
+	d := &defaultConfig.Steps[0].Annotate.Decoder
+	d.SelectedFields = d.GetDefaultFields()
+	l := &defaultConfig.Steps[0].Annotate.Label
+	l.SelectedFields = l.GetDefaultFields()
+	al := &defaultConfig.Steps[0].Annotate.Airline
+	al.SelectedFields = al.GetDefaultFields()
+	ad := &defaultConfig.Steps[0].Annotate.AircraftDatabase
+	ad.SelectedFields = ad.GetDefaultFields()
+	rb := &defaultConfig.Steps[0].Annotate.Readsb
+	rb.SelectedFields = rb.GetDefaultFields()
+	apt := &defaultConfig.Steps[0].Annotate.Airport
+	apt.SelectedFields = apt.GetDefaultFields()
+	wx := &defaultConfig.Steps[0].Annotate.Weather
+	wx.SelectedFields = wx.GetDefaultFields()
+	cl := &defaultConfig.Steps[0].Annotate.Clearance
+	cl.SelectedFields = cl.GetDefaultFields()
+	ps := &defaultConfig.Steps[0].Annotate.Planespotters
+	ps.SelectedFields = ps.GetDefaultFields()
 
 	defaults.SetDefaults(&defaultConfig)
 	configYaml, err := MarshalWithYAMLComments(defaultConfig)
@@ -102,7 +155,7 @@ func GenerateDocs() (updated bool) {
 
 	// Generate a Markdown document of all fields
 	log.Info(Content("Generating %s", fieldsDocPath))
-	var acarsFields, vdlm2Fields []string
+	var acarsFields, vdlm2Fields, hfdlFields, satcomFields []string
 	am := ACARSMessage{}.GetDefaultFields()
 	for field := range am {
 		acarsFields = append(acarsFields, field)
@@ -113,14 +166,25 @@ func GenerateDocs() (updated bool) {
 		vdlm2Fields = append(vdlm2Fields, field)
 	}
 	sort.Strings(vdlm2Fields)
+	hm := HFDLMessage{}.GetDefaultFields()
+	for field := range hm {
+		hfdlFields = append(hfdlFields, field)
+	}
+	sort.Strings(hfdlFields)
+	sm := SatcomMessage{}.GetDefaultFields()
+	for field := range sm {
+		satcomFields = append(satcomFields, field)
+	}
+	sort.Strings(satcomFields)
 	fieldsDoc = fieldsDoc +
-		fmt.Sprintf(sourceDoc, strings.Join(acarsFields, "\n- "), strings.Join(vdlm2Fields, "\n- ")) +
+		fmt.Sprintf(sourceDoc, strings.Join(acarsFields, "\n- "), strings.Join(vdlm2Fields, "\n- "), strings.Join(hfdlFields, "\n- "), strings.Join(satcomFields, "\n- ")) +
+		fmt.Sprintf(stagesDoc, strings.Join(ReassemblyFields, "\n- "), strings.Join(DeduplicationFields, "\n- "), strings.Join(ThreadingFields, "\n- ")) +
 		"## Annotators\n"
 	for _, a := range Annotators {
 		a.GetDefaultFields()
 		fieldsDoc = fieldsDoc + fmt.Sprintf("\n### %s\n\n- %s\n", a.Name(), strings.Join(a.GetDefaultFields(), "\n- "))
 	}
-	updated = updated || UpdateFile(fieldsDocPath, []byte(fieldsDoc))
+	updated = UpdateFile(fieldsDocPath, []byte(fieldsDoc)) || updated
 
 	return updated
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"reflect"
@@ -60,8 +61,16 @@ type BuiltinFilter struct {
 	TailCode string `json:",omitempty" default:"N999AP"`
 	// Only process messages that have one of these labels
 	Labels []string `json:",omitempty" default:"[H1]"`
+	// Only process messages whose label is in one of these categories, like OOOI, Weather, Free text, Maintenance or Position (requires the Label annotator). Messages without a category are filtered.
+	LabelCategories []string `json:",omitempty" default:"[OOOI,Free text]"`
+	// Only process messages with weather in one of these flight categories (VFR, MVFR, IFR or LIFR), using the worst report in the message (requires the Weather annotator). Messages without weather are filtered.
+	FlightCategories []string `json:",omitempty" default:"[IFR,LIFR]"`
+	// Only process messages with one of these kinds of clearance (PDC, DCL or Oceanic) (requires the Clearance annotator). Messages that aren't clearances are filtered.
+	ClearanceTypes []string `json:",omitempty" default:"[PDC,Oceanic]"`
 	// Only process messages that have this flight number.
 	FlightNumber string `json:",omitempty" default:"N999AP"`
+	// Only process messages from this airline, by IATA code, ICAO code or name (like UA, UAL or United Airlines). Uses the built-in airlines unless the Airline annotator ran in an earlier step.
+	Airline string `json:",omitempty" default:"UAL"`
 	// Only process messages that have ASS Status.
 	ASSStatus string `json:",omitempty" default:"anything"`
 	// Only process messages that were received above this signal strength (in dBm).
@@ -72,6 +81,10 @@ type BuiltinFilter struct {
 	Frequency float64 `json:",omitempty" default:"136.950"`
 	// Only process messages with this station ID.
 	StationID string `json:",omitempty" default:"N12346"`
+	// Only process SATCOM messages received from this satellite.
+	Satellite string `json:",omitempty" default:"Inmarsat 4-F3 (98W)"`
+	// Only process SATCOM messages relayed by this ground earth station ID.
+	GroundEarthStation string `json:",omitempty" default:"90"`
 	// Only process messages that were from a ground-based transmitter - determined by the presence (From aircraft) or lack of (From ground) a flight number.
 	FromTower *bool `json:",omitempty" default:"true"`
 	// Only process messages that were from an aircraft - determined by the presence (From aircraft) or lack of (From ground) a flight number.
@@ -179,6 +192,29 @@ var (
 			flightNumberMatches := f.FlightNumber == fn
 			return !flightNumberMatches, reason, nil
 		},
+		"Airline": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
+			airline := AirlineAnnotatorResult{
+				AirlineIATA: GetAPMessageCommonFieldAsString(m, "AirlineIATA"),
+				AirlineICAO: GetAPMessageCommonFieldAsString(m, "AirlineICAO"),
+				AirlineName: GetAPMessageCommonFieldAsString(m, "AirlineName"),
+			}
+			// Look it up in the built-in airlines if the Airline annotator
+			// didn't, so any AirlineFile only applies after the annotator runs
+			if airline == (AirlineAnnotatorResult{}) {
+				airline, _ = Airlines.ParseFlightNumber(GetAPMessageCommonFieldAsString(m, "FlightNumber"))
+			}
+			if airline == (AirlineAnnotatorResult{}) {
+				return true, fmt.Sprintf(fieldWasEmpty, "AirlineICAO"), nil
+			}
+			match := slices.ContainsFunc([]string{airline.AirlineIATA, airline.AirlineICAO, airline.AirlineName}, func(a string) bool {
+				return a != "" && strings.EqualFold(a, f.Airline)
+			})
+			if !match {
+				name := cmp.Or(airline.AirlineICAO, airline.AirlineIATA, airline.AirlineName)
+				reason = fmt.Sprintf("airline %s is not %s", name, f.Airline)
+			}
+			return !match, reason, nil
+		},
 		"Frequency": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
 			fmhz := GetAPMessageCommonFieldAsFloat64(m, "FrequencyMHz")
 			fhz := GetAPMessageCommonFieldAsFloat64(m, "FrequencyHz")
@@ -199,6 +235,14 @@ var (
 			stationIDMatches := f.StationID == GetAPMessageCommonFieldAsString(m, "StationId")
 			return !stationIDMatches, reason, nil
 		},
+		"Satellite": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
+			satelliteMatches := f.Satellite == GetAPMessageCommonFieldAsString(m, "Satellite")
+			return !satelliteMatches, reason, nil
+		},
+		"GroundEarthStation": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
+			gesMatches := strings.EqualFold(f.GroundEarthStation, GetAPMessageCommonFieldAsString(m, "GroundEarthStation"))
+			return !gesMatches, reason, nil
+		},
 		"AboveMinimumSignal": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
 			aboveSignalStrength := f.AboveSignaldBm >= GetAPMessageCommonFieldAsFloat64(m, "SignaldBm")
 			return !aboveSignalStrength, reason, nil
@@ -316,6 +360,33 @@ var (
 			}
 			return !match, reason, nil
 		},
+		"LabelCategories": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
+			field := "LabelCategory"
+			// Messages the Label annotator hasn't seen aren't in any category
+			match, category := CommonFieldIsOneOf(m, field, f.LabelCategories)
+			if category == "" {
+				reason = fmt.Sprintf(fieldWasEmpty, field)
+			}
+			return !match, reason, nil
+		},
+		"FlightCategories": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
+			field := "Weather.WorstFlightCategory"
+			// Messages without weather aren't in any flight category
+			match, category := CommonFieldIsOneOf(m, field, f.FlightCategories)
+			if category == "" {
+				reason = fmt.Sprintf(fieldWasEmpty, field)
+			}
+			return !match, reason, nil
+		},
+		"ClearanceTypes": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
+			field := "ClearanceType"
+			// Messages that aren't clearances aren't any kind of clearance
+			match, clearanceType := CommonFieldIsOneOf(m, field, f.ClearanceTypes)
+			if clearanceType == "" {
+				reason = fmt.Sprintf(fieldWasEmpty, field)
+			}
+			return !match, reason, nil
+		},
 		"RequireRegexMatches": func(f BuiltinFilter, m APMessage) (filter bool, reason string, err error) {
 			field := "MessageText"
 			mt := GetAPMessageCommonFieldAsString(m, field)
@@ -339,6 +410,19 @@ func FreetextTermPresent(m string) (present bool) {
 	return RequireNTerms(freetextTerms, m, 1) || strings.HasPrefix(m, "DISP")
 }
 
+// Returns true if the common field is one of values, ignoring case, along
+// with the field's value
+func CommonFieldIsOneOf(m APMessage, field string, values []string) (match bool, value string) {
+	value = GetAPMessageCommonFieldAsString(m, field)
+	if value == "" {
+		return false, value
+	}
+	match = slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+	return match, value
+}
+
 // Checks that every item in a string slice is present in a string,
 // if not it returns true to filter
 func RequireAllTerms(sl []string, m string) (present bool) {
@@ -419,13 +503,19 @@ func (d BuiltinFilter) FilterSimilarAPMessage(m APMessage) (filter bool, reason
 	if d.PreviousMessageSimilarity.MaximumLookBehind != 0 {
 		defaultMaxLookbehind = d.PreviousMessageSimilarity.MaximumLookBehind
 	}
-	am, vm, msgs := []ACARSMessage{}, []VDLM2Message{}, []string{}
+	am, vm, hm, sm, msgs := []ACARSMessage{}, []VDLM2Message{}, []HFDLMessage{}, []SatcomMessage{}, []string{}
 	db.Where(ACARSMessage{Processed: true}).
 		Limit(defaultMaxLookbehind).
 		Find(&am)
 	db.Where(VDLM2Message{Processed: true}).
 		Limit(defaultMaxLookbehind).
 		Find(&vm)
+	db.Where(HFDLMessage{Processed: true}).
+		Limit(defaultMaxLookbehind).
+		Find(&hm)
+	db.Where(SatcomMessage{Processed: true}).
+		Limit(defaultMaxLookbehind).
+		Find(&sm)
 	for _, acm := range am {
 		acmapm := FormatAsAPMessage(acm, "")
 		acmts := GetAPMessageCommonFieldAsString(acmapm, field)
@@ -440,6 +530,21 @@ func (d BuiltinFilter) FilterSimilarAPMessage(m APMessage) (filter bool, reason
 			msgs = append(msgs, acmts)
 		}
 	}
+	for _, acm := range hm {
+		acmapm := FormatAsAPMessage(acm, "")
+		acmts := GetAPMessageCommonFieldAsString(acmapm, field)
+		if acmts != "" {
+			msgs = append(msgs, acmts)
+		}
+	}
+	for _, acm := range sm {
+		// SATCOM messages come in two formats, so use Prepare() to get the
+		// common text field.
+		acmts := GetAPMessageCommonFieldAsString(acm.Prepare(), field)
+		if acmts != "" {
+			msgs = append(msgs, acmts)
+		}
+	}
 	for _, mcmp := range msgs {
 		similarity := strutil.Similarity(mt, mcmp, metrics.NewHamming())
 		if similarity >= d.PreviousMessageSimilarity.Similarity {
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestBuiltinFilterAirline(t *testing.T) {
+	tests := []struct {
+		name       string
+		m          APMessage
+		want       bool
+		wantReason string
+	}{
+		{
+			name: "built-in airline by ICAO code",
+			m:    APMessage{ACARSProcessorPrefix + "FlightNumber": "UAL123"},
+			want: false,
+		},
+		{
+			name: "built-in airline by IATA code",
+			m:    APMessage{ACARSProcessorPrefix + "FlightNumber": "UA0123"},
+			want: false,
+		},
+		{
+			name:       "another airline",
+			m:          APMessage{ACARSProcessorPrefix + "FlightNumber": "DAL456"},
+			want:       true,
+			wantReason: "airline DAL is not UAL",
+		},
+		{
+			name: "from the Airline annotator",
+			m: APMessage{
+				ACARSProcessorPrefix + "FlightNumber": "XYZ123",
+				ACARSProcessorPrefix + "AirlineICAO":  "UAL",
+			},
+			want: false,
+		},
+		{
+			name:       "unknown airline",
+			m:          APMessage{ACARSProcessorPrefix + "FlightNumber": "123"},
+			want:       true,
+			wantReason: "AirlineICAO field was empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason, err := BuiltinFilterFunctions["Airline"](BuiltinFilter{Airline: "UAL"}, tt.m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("filtered %v (%q), want %v (%q)", got, reason, tt.want, tt.wantReason)
+			}
+		})
+	}
+}
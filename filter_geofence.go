@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// Zones from GeoJSON files, keyed by path and name property, loaded the
+	// first time they're used
+	geofenceFiles     = map[string]geofenceFile{}
+	geofenceFilesLock sync.Mutex
+)
+
+type GeofenceFilter struct {
+	Filterer
+	// Whether or not to filter the message if the filter has an error
+	FilterOnFailure bool `json:",omitempty" default:"false"`
+	// Inverse logic (for example, Invert: true means messages from aircraft inside the zones are FILTERED)
+	Invert bool `json:",omitempty" default:"false"`
+	// Only process messages from aircraft inside one of these zones.
+	Zones []GeofenceZone `json:",omitempty"`
+	// Only process messages from aircraft inside a Polygon or MultiPolygon in one of these GeoJSON files.
+	GeoJSONFiles []string `json:",omitempty" jsonschema:"example=./county.geojson"`
+	// GeoJSON feature property to use as the zone name.
+	NameProperty string `json:",omitempty" jsonschema:"default=name" default:"name"`
+	// Only process messages from aircraft at or above this altitude in feet, in any zone.
+	MinimumAltitudeFeet float64 `json:",omitempty" default:"0"`
+	// Only process messages from aircraft at or below this altitude in feet, in any zone (0 for no limit).
+	MaximumAltitudeFeet float64 `json:",omitempty" default:"0"`
+	// Filter messages that don't have an aircraft position (or altitude, if there's an altitude band) instead of letting them through.
+	FilterIfNoPosition bool `json:",omitempty" default:"false"`
+}
+
+type GeofenceZone struct {
+	// Added to messages inside the zone as ACARSProcessor.GeofenceZone.
+	Name string `jsonschema:"required"`
+	// Each polygon is a list of LAT,LON points around its edge. Aircraft in any of them are in the zone.
+	Polygons [][]string `jsonschema:"required"`
+	// Only count aircraft at or above this altitude in feet as in the zone.
+	MinimumAltitudeFeet float64 `json:",omitempty"`
+	// Only count aircraft at or below this altitude in feet as in the zone (0 for no limit).
+	MaximumAltitudeFeet float64 `json:",omitempty"`
+}
+
+// A zone ready to check points against
+type geofence struct {
+	Name                string
+	Polygons            []geofencePolygon
+	MinimumAltitudeFeet float64
+	MaximumAltitudeFeet float64
+}
+
+// Rings are lists of [lon, lat] like in GeoJSON. The first is the outside of
+// the polygon and any others are holes in it.
+type geofencePolygon [][][2]float64
+
+type geofenceFile struct {
+	zones []geofence
+	err   error
+}
+
+func (f GeofenceFilter) Name() string {
+	return reflect.TypeOf(f).Name()
+}
+
+func (f GeofenceFilter) Configured() bool {
+	return !reflect.DeepEqual(f, GeofenceFilter{})
+}
+
+// Returns the configured zones followed by the ones from GeoJSON files
+func (f GeofenceFilter) Geofences() (zones []geofence, err error) {
+	for _, z := range f.Zones {
+		g := geofence{Name: z.Name, MinimumAltitudeFeet: z.MinimumAltitudeFeet, MaximumAltitudeFeet: z.MaximumAltitudeFeet}
+		for _, points := range z.Polygons {
+			var ring [][2]float64
+			for _, p := range points {
+				c, err := ParseGeolocation(p)
+				if err != nil {
+					return nil, fmt.Errorf("zone %s: %w", z.Name, err)
+				}
+				ring = append(ring, [2]float64{c.Lon, c.Lat})
+			}
+			if len(ring) < 3 {
+				return nil, fmt.Errorf("zone %s: polygons need at least 3 points", z.Name)
+			}
+			g.Polygons = append(g.Polygons, geofencePolygon{ring})
+		}
+		zones = append(zones, g)
+	}
+	for _, path := range f.GeoJSONFiles {
+		fz, err := GeofencesFromFile(path, f.NameProperty)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, fz...)
+	}
+	return zones, nil
+}
+
+// Returns the zones in a GeoJSON file, loading it if it hasn't been yet
+func GeofencesFromFile(path, nameProperty string) ([]geofence, error) {
+	if nameProperty == "" {
+		nameProperty = "name"
+	}
+	geofenceFilesLock.Lock()
+	defer geofenceFilesLock.Unlock()
+	key := path + "#" + nameProperty
+	if gf, ok := geofenceFiles[key]; ok {
+		return gf.zones, gf.err
+	}
+	zones, err := LoadGeoJSON(path, nameProperty)
+	if err != nil {
+		err = fmt.Errorf("unable to load geofences from %s: %w", path, err)
+	} else {
+		log.Info(Success("loaded %d geofences from %s", len(zones), path))
+	}
+	// Don't try again for every message
+	geofenceFiles[key] = geofenceFile{zones: zones, err: err}
+	return zones, err
+}
+
+type geoJSONObject struct {
+	Type       string          `json:"type"`
+	Features   []geoJSONObject `json:"features"`
+	Geometry   *geoJSONObject  `json:"geometry"`
+	Geometries []geoJSONObject `json:"geometries"`
+	Properties map[string]any  `json:"properties"`
+	Coords     json.RawMessage `json:"coordinates"`
+}
+
+// Reads the Polygons and MultiPolygons from a GeoJSON FeatureCollection,
+// Feature or geometry. Features are named by their nameProperty, or by the
+// file and their position in it if they don't have one.
+func LoadGeoJSON(path, nameProperty string) (zones []geofence, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var o geoJSONObject
+	if err := json.Unmarshal(b, &o); err != nil {
+		return nil, err
+	}
+	var walk func(o geoJSONObject, name string) error
+	walk = func(o geoJSONObject, name string) error {
+		if n, ok := o.Properties[nameProperty]; ok && n != nil {
+			name = fmt.Sprint(n)
+		}
+		switch o.Type {
+		case "FeatureCollection":
+			for i, feature := range o.Features {
+				if err := walk(feature, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+					return err
+				}
+			}
+		case "Feature":
+			if o.Geometry != nil {
+				return walk(*o.Geometry, name)
+			}
+		case "GeometryCollection":
+			for _, g := range o.Geometries {
+				if err := walk(g, name); err != nil {
+					return err
+				}
+			}
+		case "Polygon":
+			var p geofencePolygon
+			if err := json.Unmarshal(o.Coords, &p); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			zones = append(zones, geofence{Name: name, Polygons: []geofencePolygon{p}})
+		case "MultiPolygon":
+			var mp []geofencePolygon
+			if err := json.Unmarshal(o.Coords, &mp); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			zones = append(zones, geofence{Name: name, Polygons: mp})
+		}
+		return nil
+	}
+	if err := walk(o, strings.TrimSuffix(path, ".geojson")); err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return nil, errors.New("no polygons found")
+	}
+	return zones, nil
+}
+
+// Whether a point is inside the polygon and not in any of its holes
+func (p geofencePolygon) Contains(lat, lon float64) bool {
+	for i, ring := range p {
+		if inRing(ring, lat, lon) != (i == 0) {
+			return false
+		}
+	}
+	return len(p) > 0
+}
+
+// Ray casting, counting how many edges a line east from the point crosses
+func inRing(ring [][2]float64, lat, lon float64) (in bool) {
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
+
+// Whether the aircraft is in the zone, checking altitude only if the zone
+// has a band
+func (g geofence) Contains(lat, lon, altitude float64, hasAltitude bool) bool {
+	if (g.MinimumAltitudeFeet != 0 || g.MaximumAltitudeFeet != 0) && !hasAltitude {
+		return false
+	}
+	if g.MinimumAltitudeFeet != 0 && altitude < g.MinimumAltitudeFeet {
+		return false
+	}
+	if g.MaximumAltitudeFeet != 0 && altitude > g.MaximumAltitudeFeet {
+		return false
+	}
+	for _, p := range g.Polygons {
+		if p.Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the aircraft's altitude, preferring barometric. Annotators store
+// altitudes as whole or decimal numbers.
+func AircraftAltitudeFeet(m APMessage) (float64, bool) {
+	for _, field := range []string{"AircraftBarometerAltitudeFeet", "AircraftAltitudeFeet"} {
+		switch v := m[ACARSProcessorPrefix+field].(type) {
+		case int:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		case float64:
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Filters messages from aircraft outside every zone. The first zone the
+// aircraft is in is added to the message as ACARSProcessor.GeofenceZone.
+func (f GeofenceFilter) Filter(m APMessage) (filter bool, reason string, err error) {
+	zones, err := f.Geofences()
+	if err != nil {
+		return f.FilterOnFailure, "error loading zones", err
+	}
+	lat := GetAPMessageCommonFieldAsFloat64(m, "AircraftLatitude")
+	lon := GetAPMessageCommonFieldAsFloat64(m, "AircraftLongitude")
+	if lat == 0 && lon == 0 {
+		return f.FilterIfNoPosition, fmt.Sprintf(fieldWasEmpty, "AircraftLatitude"), nil
+	}
+	altitude, hasAltitude := AircraftAltitudeFeet(m)
+	if f.MinimumAltitudeFeet != 0 || f.MaximumAltitudeFeet != 0 {
+		if !hasAltitude {
+			return f.FilterIfNoPosition, fmt.Sprintf(fieldWasEmpty, "AircraftBarometerAltitudeFeet"), nil
+		}
+		inBand := altitude >= f.MinimumAltitudeFeet &&
+			(f.MaximumAltitudeFeet == 0 || altitude <= f.MaximumAltitudeFeet)
+		if !inBand {
+			return !f.Invert, fmt.Sprintf("altitude %.0f ft", altitude), nil
+		}
+	}
+	// An altitude band on its own is enough
+	if len(zones) == 0 {
+		return f.Invert, fmt.Sprintf("altitude %.0f ft", altitude), nil
+	}
+	for _, z := range zones {
+		if z.Contains(lat, lon, altitude, hasAltitude) {
+			// Later steps can use the zone, whether or not this filters
+			m[ACARSProcessorPrefix+"GeofenceZone"] = z.Name
+			return f.Invert, "in " + z.Name, nil
+		}
+	}
+	return !f.Invert, "not in any zone", nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeofencePolygonContains(t *testing.T) {
+	// A square around the Bay Area with a hole around San Francisco
+	square := geofencePolygon{
+		{{-123, 37}, {-121, 37}, {-121, 38.5}, {-123, 38.5}, {-123, 37}},
+		{{-122.6, 37.6}, {-122.3, 37.6}, {-122.3, 37.9}, {-122.6, 37.9}, {-122.6, 37.6}},
+	}
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     bool
+	}{
+		{name: "inside", lat: 37.4, lon: -121.9, want: true},
+		{name: "in the hole", lat: 37.77, lon: -122.42, want: false},
+		{name: "north", lat: 39, lon: -122, want: false},
+		{name: "west", lat: 37.5, lon: -123.5, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := square.Contains(tt.lat, tt.lon); got != tt.want {
+				t.Errorf("Contains(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeofenceFilter(t *testing.T) {
+	zone := GeofenceZone{
+		Name:     "bay",
+		Polygons: [][]string{{"37,-123", "37,-121", "38.5,-121", "38.5,-123"}},
+	}
+	// tar1090 and readsb store barometric altitude as int64, ADS-B Exchange
+	// geometric altitude as float64
+	at := func(lat, lon float64, altitude any) APMessage {
+		m := APMessage{
+			ACARSProcessorPrefix + "AircraftLatitude":  lat,
+			ACARSProcessorPrefix + "AircraftLongitude": lon,
+		}
+		switch altitude.(type) {
+		case int64:
+			m[ACARSProcessorPrefix+"AircraftBarometerAltitudeFeet"] = altitude
+		case float64:
+			m[ACARSProcessorPrefix+"AircraftAltitudeFeet"] = altitude
+		}
+		return m
+	}
+	banded := zone
+	banded.MaximumAltitudeFeet = 10000
+	tests := []struct {
+		name   string
+		filter GeofenceFilter
+		m      APMessage
+		want   bool
+		zone   string
+	}{
+		{name: "in zone", filter: GeofenceFilter{Zones: []GeofenceZone{zone}}, m: at(37.5, -122, int64(35000)), want: false, zone: "bay"},
+		{name: "outside zone", filter: GeofenceFilter{Zones: []GeofenceZone{zone}}, m: at(40, -122, int64(35000)), want: true},
+		{name: "outside zone inverted", filter: GeofenceFilter{Zones: []GeofenceZone{zone}, Invert: true}, m: at(40, -122, int64(35000)), want: false},
+		{name: "no position", filter: GeofenceFilter{Zones: []GeofenceZone{zone}}, m: APMessage{}, want: false},
+		{name: "no position filtered", filter: GeofenceFilter{Zones: []GeofenceZone{zone}, FilterIfNoPosition: true}, m: APMessage{}, want: true},
+		{name: "above filter band", filter: GeofenceFilter{Zones: []GeofenceZone{zone}, MaximumAltitudeFeet: 10000}, m: at(37.5, -122, int64(35000)), want: true},
+		{name: "in filter band", filter: GeofenceFilter{Zones: []GeofenceZone{zone}, MaximumAltitudeFeet: 10000}, m: at(37.5, -122, int64(5000)), want: false, zone: "bay"},
+		{name: "below filter band", filter: GeofenceFilter{MinimumAltitudeFeet: 18000}, m: at(37.5, -122, float64(12000.5)), want: true},
+		{name: "filter band only", filter: GeofenceFilter{MinimumAltitudeFeet: 18000}, m: at(37.5, -122, float64(35000)), want: false},
+		{name: "band without altitude", filter: GeofenceFilter{MinimumAltitudeFeet: 18000, FilterIfNoPosition: true}, m: at(37.5, -122, nil), want: true},
+		{name: "above zone band", filter: GeofenceFilter{Zones: []GeofenceZone{banded}}, m: at(37.5, -122, int64(35000)), want: true},
+		{name: "in zone band", filter: GeofenceFilter{Zones: []GeofenceZone{banded}}, m: at(37.5, -122, int64(3000)), want: false, zone: "bay"},
+		{name: "zone band without altitude", filter: GeofenceFilter{Zones: []GeofenceZone{banded}}, m: at(37.5, -122, nil), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason, err := tt.filter.Filter(tt.m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("filter %v (%s), want %v", got, reason, tt.want)
+			}
+			if z, _ := tt.m[ACARSProcessorPrefix+"GeofenceZone"].(string); z != tt.zone {
+				t.Errorf("zone %q, want %q", z, tt.zone)
+			}
+		})
+	}
+}
+
+func TestLoadGeoJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.geojson")
+	geojson := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"name":"west"},"geometry":{"type":"Polygon","coordinates":[[[-123,37],[-122,37],[-122,38],[-123,38],[-123,37]]]}},
+		{"type":"Feature","properties":{},"geometry":{"type":"MultiPolygon","coordinates":[[[[-121,37],[-120,37],[-120,38],[-121,38],[-121,37]]]]}},
+		{"type":"Feature","properties":{"name":"point"},"geometry":{"type":"Point","coordinates":[-122,37]}}
+	]}`
+	if err := os.WriteFile(path, []byte(geojson), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	zones, err := LoadGeoJSON(path, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 2 {
+		t.Fatalf("loaded %d zones, want 2", len(zones))
+	}
+	if zones[0].Name != "west" || !zones[0].Contains(37.5, -122.5, 0, false) {
+		t.Errorf("first zone %q doesn't contain 37.5,-122.5", zones[0].Name)
+	}
+	if zones[1].Name == "" || !zones[1].Contains(37.5, -120.5, 0, false) {
+		t.Errorf("second zone %q doesn't contain 37.5,-120.5", zones[1].Name)
+	}
+}
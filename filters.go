@@ -13,6 +13,7 @@ import (
 func (f FilterStep) Filter(m APMessage) (name string, filtered bool, errs error) {
 	filters := []Filterer{
 		f.Builtin,
+		f.Geofence,
 		f.Ollama,
 		f.OpenAI,
 	}
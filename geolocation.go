@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jftuga/geodist"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	earthRadiusMeters = 6371008.8
+	metersPerFoot     = 0.3048
+)
+
+// Where distances to aircraft are measured from
+type ReferenceLocation struct {
+	// The station's name, or empty if an annotator's ReferenceGeolocation is
+	// used
+	Name            string
+	Coord           geodist.Coord
+	ElevationMeters float64
+}
+
+// An aircraft's position relative to a ReferenceLocation
+type RelativePosition struct {
+	DistanceKm     float64
+	DistanceMi     float64
+	BearingDegrees float64
+	// Degrees above the horizon, allowing for the curve of the earth
+	ElevationAngleDegrees float64
+}
+
+// Parses a geolocation like "35.6244416,139.7753782"
+func ParseGeolocation(s string) (c geodist.Coord, err error) {
+	coords := strings.Split(s, ",")
+	if len(coords) != 2 {
+		return c, fmt.Errorf("geolocation %q is not in the format 'LAT,LON'", s)
+	}
+	if c.Lat, err = strconv.ParseFloat(strings.TrimSpace(coords[0]), 64); err != nil {
+		return c, fmt.Errorf("latitude in %q is not a number", s)
+	}
+	if c.Lon, err = strconv.ParseFloat(strings.TrimSpace(coords[1]), 64); err != nil {
+		return c, fmt.Errorf("longitude in %q is not a number", s)
+	}
+	if c.Lat < -90 || c.Lat > 90 {
+		return c, fmt.Errorf("latitude in %q is not between -90 and 90", s)
+	}
+	if c.Lon < -180 || c.Lon > 180 {
+		return c, fmt.Errorf("longitude in %q is not between -180 and 180", s)
+	}
+	return c, nil
+}
+
+// Checks every station's geolocation
+func ValidateStations(stations map[string]StationConfig) error {
+	for id, s := range stations {
+		if _, err := ParseGeolocation(s.Geolocation); err != nil {
+			return fmt.Errorf("station %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Returns where the station that heard the message is, or fallback (LAT,LON)
+// if the station isn't in Stations
+func ReferenceLocationFor(m APMessage, fallback string) (r ReferenceLocation, err error) {
+	if s, ok := config.Stations[GetAPMessageCommonFieldAsString(m, "StationId")]; ok {
+		r.Coord, err = ParseGeolocation(s.Geolocation)
+		r.Name, r.ElevationMeters = s.Name, s.ElevationMeters
+		return r, err
+	}
+	if fallback == "" {
+		log.Debug(Aside("no station or reference geolocation, measuring from 0,0"))
+		return r, nil
+	}
+	r.Coord, err = ParseGeolocation(fallback)
+	return r, err
+}
+
+// Returns the distance, bearing and elevation angle from r to an aircraft.
+// Aircraft at 0,0 are assumed to not have a position and get zeroes.
+func (r ReferenceLocation) PositionOf(lat, lon, altitudeFeet float64) (p RelativePosition, err error) {
+	if lat == 0 && lon == 0 {
+		return p, nil
+	}
+	aircraft := geodist.Coord{Lat: lat, Lon: lon}
+	p.DistanceMi, p.DistanceKm, err = geodist.VincentyDistance(r.Coord, aircraft)
+	if err != nil {
+		return p, fmt.Errorf("error calculating distance: %s", err)
+	}
+	p.BearingDegrees = InitialBearing(r.Coord, aircraft)
+	// The angle between the station and aircraft at the earth's center
+	theta := p.DistanceKm * 1000 / earthRadiusMeters
+	r1 := earthRadiusMeters + r.ElevationMeters
+	r2 := earthRadiusMeters + altitudeFeet*metersPerFoot
+	p.ElevationAngleDegrees = math.Atan2(r2*math.Cos(theta)-r1, r2*math.Sin(theta)) * 180 / math.Pi
+	return p, nil
+}
+
+// Returns the bearing in degrees from true north to set off from a towards b
+func InitialBearing(a, b geodist.Coord) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dlon := (b.Lon - a.Lon) * math.Pi / 180
+	y := math.Sin(dlon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dlon)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Clear out expired responses when the cache gets this big
+const httpCacheSweepSize = 1000
+
+var (
+	ErrRateLimited = errors.New("rate limit reached, skipping lookup")
+
+	httpCache = HTTPResponseCache{
+		responses: map[string]cachedResponse{},
+		inflight:  map[string]*inflightRequest{},
+	}
+	// Rate limiters, keyed by API so every step using the same one shares a
+	// limit
+	rateLimiters     = map[string]*TokenBucket{}
+	rateLimitersLock sync.Mutex
+	httpCacheClient  = &http.Client{Timeout: 30 * time.Second}
+)
+
+// Caches successful responses from annotator APIs in memory (and optionally
+// the database) so lookups for the same thing are only made once per TTL
+type HTTPResponseCache struct {
+	sync.Mutex
+	responses map[string]cachedResponse
+	inflight  map[string]*inflightRequest
+}
+
+type cachedResponse struct {
+	Body      []byte
+	ExpiresAt time.Time
+}
+
+// A request being made, which other requests for the same key wait on
+// instead of making their own
+type inflightRequest struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// A response saved to the database
+type CachedHTTPResponse struct {
+	gorm.Model
+	CacheKey  string `gorm:"uniqueIndex;size:255"`
+	Body      []byte
+	ExpiresAt time.Time
+}
+
+// Options for caching an annotator's requests
+type HTTPCacheOptions struct {
+	// Cache key, which should leave out anything that changes between
+	// otherwise identical requests (like cache busters)
+	Key string
+	TTL time.Duration
+	// Save responses in the database so they survive restarts
+	Persist bool
+	// Limiter to take a token from before making the request, if any
+	Limiter *TokenBucket
+}
+
+// Returns the body of a successful response to req, from the cache if it's
+// there. Concurrent calls with the same key share one request.
+func CachedHTTPRequest(req *http.Request, o HTTPCacheOptions) ([]byte, error) {
+	c := &httpCache
+	c.Lock()
+	if r, ok := c.responses[o.Key]; ok && time.Now().Before(r.ExpiresAt) {
+		c.Unlock()
+		return r.Body, nil
+	}
+	if i, ok := c.inflight[o.Key]; ok {
+		c.Unlock()
+		<-i.done
+		return i.body, i.err
+	}
+	i := &inflightRequest{done: make(chan struct{})}
+	c.inflight[o.Key] = i
+	c.Unlock()
+
+	i.body, i.err = fetchAndCache(req, o)
+
+	c.Lock()
+	delete(c.inflight, o.Key)
+	c.Unlock()
+	close(i.done)
+	return i.body, i.err
+}
+
+func fetchAndCache(req *http.Request, o HTTPCacheOptions) ([]byte, error) {
+	if o.Persist && o.TTL > 0 {
+		var saved CachedHTTPResponse
+		if db.Where("cache_key = ? AND expires_at > ?", o.Key, time.Now()).Limit(1).Find(&saved).RowsAffected > 0 {
+			httpCache.store(o.Key, cachedResponse{Body: saved.Body, ExpiresAt: saved.ExpiresAt})
+			return saved.Body, nil
+		}
+	}
+	if o.Limiter != nil && !o.Limiter.Allow() {
+		return nil, ErrRateLimited
+	}
+	resp, err := httpCacheClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, req.URL.Host)
+	}
+	if o.TTL <= 0 {
+		return body, nil
+	}
+	r := cachedResponse{Body: body, ExpiresAt: time.Now().Add(o.TTL)}
+	httpCache.store(o.Key, r)
+	if o.Persist {
+		saved := CachedHTTPResponse{CacheKey: o.Key}
+		err := db.Where(CachedHTTPResponse{CacheKey: o.Key}).
+			Assign(CachedHTTPResponse{Body: r.Body, ExpiresAt: r.ExpiresAt}).
+			FirstOrCreate(&saved).Error
+		if err != nil {
+			log.Warn(Attention("unable to save cached response for %s: %s", req.URL.Host, err))
+		}
+	}
+	return body, nil
+}
+
+func (c *HTTPResponseCache) store(key string, r cachedResponse) {
+	c.Lock()
+	defer c.Unlock()
+	if len(c.responses) >= httpCacheSweepSize {
+		for k, v := range c.responses {
+			if time.Now().After(v.ExpiresAt) {
+				delete(c.responses, k)
+			}
+		}
+	}
+	c.responses[key] = r
+}
+
+// A token bucket that refills at a steady rate, allowing short bursts
+type TokenBucket struct {
+	sync.Mutex
+	// Tokens added per second
+	Rate float64
+	// Most tokens the bucket can hold
+	Burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(perMinute float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		Rate:   perMinute / 60,
+		Burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Takes a token if there is one
+func (b *TokenBucket) Allow() bool {
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now()
+	b.tokens = min(b.Burst, b.tokens+now.Sub(b.last).Seconds()*b.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Returns the shared rate limiter for name, or nil if perMinute is 0 or
+// negative (no limit). The limiter is created the first time it's asked for.
+func RateLimiter(name string, perMinute float64, burst int) *TokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	rateLimitersLock.Lock()
+	defer rateLimitersLock.Unlock()
+	if b, ok := rateLimiters[name]; ok {
+		return b
+	}
+	b := NewTokenBucket(perMinute, burst)
+	rateLimiters[name] = b
+	return b
+}
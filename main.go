@@ -19,10 +19,14 @@ var (
 
 func main() {
 	var generateSchema, interactive bool
+	var replayPath string
+	var replaySpeed float64
 	// flags declaration using flag package
 	flag.StringVar(&configFilePath, "c", configFilePath, "Config file path.")
 	flag.BoolVar(&generateSchema, "s", false, "Generate schema.json, then exit.")
 	flag.BoolVar(&interactive, "i", false, "Interactive - read from STDIN only.")
+	flag.StringVar(&replayPath, "r", "", "Replay messages from a JSONL file (optionally gzipped) or a directory of them, then exit.")
+	flag.Float64Var(&replaySpeed, "speed", 1, "Replay speed multiplier, for example 10 for 10x. 0 replays as fast as possible.")
 	flag.Parse()
 
 	// Generate schema only and then exit
@@ -39,10 +43,23 @@ func main() {
 
 	LoadConfig()
 	ConfigureLogging()
+	if replayPath != "" {
+		// Replays use a throwaway database so they don't pick up unprocessed
+		// messages or change anything in the real one
+		config.ACARSProcessorSettings.Database.Enabled = false
+		config.ACARSProcessorSettings.Database.Type = "sqlite"
+	}
 	if err := LoadSavedMessages(); err != nil {
 		log.Fatal(Attention("unable to initialize database: %s", err))
 	}
 
+	StartTar1090Pollers()
+	StartReadsbFeeds()
+	if replayPath != "" {
+		ReplayMessages(replayPath, replaySpeed)
+		return
+	}
+
 	if interactive {
 		go SubscribeToStandardIn()
 	} else {
@@ -49,17 +49,14 @@ type ACARSMessage struct {
 	MessageText      string `json:"text" ap:"MessageText"`
 	MessageNumber    string `json:"msgno"`
 	FlightNumber     string `json:"flight" ap:"FlightNumber"`
+	// Set by acarsdec on the last block of a message, unset if the decoder
+	// doesn't say
+	End *bool `json:"end,omitempty"`
 }
 
 func (a ACARSMessage) Prepare() (result APMessage) {
 	// Chop off leading periods
 	a.AircraftTailCode, _ = strings.CutPrefix(a.AircraftTailCode, ".")
-	var thumbnail, link string
-	img := getImageByRegistration(a.AircraftTailCode)
-	if img != nil {
-		thumbnail = img.ThumbnailLarge.Src
-		link = img.Link
-	}
 	result = FormatAsAPMessage(a, a.Name())
 
 	// Sometimes tail numbers lead with periods, chop them off
@@ -68,8 +65,6 @@ func (a ACARSMessage) Prepare() (result APMessage) {
 	// Extra helper or common fields
 	result[ACARSProcessorPrefix+"TrackingLink"] = FlightAwareRoot + a.AircraftTailCode
 	result[ACARSProcessorPrefix+"PhotosLink"] = FlightAwarePhotos + a.AircraftTailCode
-	result[ACARSProcessorPrefix+"ThumbnailLink"] = thumbnail
-	result[ACARSProcessorPrefix+"ImageLink"] = link
 	result[ACARSProcessorPrefix+"TranslateLink"] = fmt.Sprintf(GoogleTranslateLink, url.QueryEscape(a.MessageText))
 	result[ACARSProcessorPrefix+"ACARSDramaTailNumberLink"] = fmt.Sprintf(ACARSDramaTailNumberLink, a.AircraftTailCode)
 	result[ACARSProcessorPrefix+"UnixTimestamp"] = int64(a.Timestamp)
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func (h HFDLMessage) Name() string {
+	return "HFDLMessage"
+}
+
+func (h HFDLMessage) GetDefaultFields() APMessage {
+	return HFDLMessage{}.Prepare()
+}
+
+type HFDLEndpoint struct {
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type HFDLPDUType struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// This is the format dumphfdl (and ACARSHub) sends
+type HFDLMessage struct {
+	gorm.Model
+	ProcessingStartedAt  time.Time
+	ProcessingFinishedAt time.Time
+	Processed            bool `gorm:"index"`
+	// The rest of the struct is the actual message from dumphfdl
+	HFDL struct {
+		App struct {
+			Name               string `json:"name"`
+			Version            string `json:"ver"`
+			Proxied            bool   `json:"proxied"`
+			ProxiedBy          string `json:"proxied_by"`
+			ACARSRouterVersion string `json:"acars_router_version"`
+			ACARSRouterUUID    string `json:"acars_router_uuid"`
+		} `json:"app" gorm:"embedded"`
+		Station   string `json:"station" ap:"StationId"`
+		Timestamp struct {
+			UnixTimestamp int64 `json:"sec" ap:"UnixTimestamp"`
+			Microseconds  int64 `json:"usec"`
+		} `json:"t" gorm:"embedded"`
+		FrequencyHz   int     `json:"freq" ap:"FrequencyHz"`
+		BitRate       int     `json:"bit_rate"`
+		SignalLevel   float64 `json:"sig_level" ap:"SignalLeveldBm"`
+		NoiseLevel    float64 `json:"noise_level"`
+		FrequencySkew float64 `json:"freq_skew"`
+		Slot          string  `json:"slot"`
+		LPDU          struct {
+			Error       bool         `json:"err"`
+			Source      HFDLEndpoint `json:"src" gorm:"embedded;embeddedPrefix:src_"`
+			Destination HFDLEndpoint `json:"dst" gorm:"embedded;embeddedPrefix:dst_"`
+			Type        HFDLPDUType  `json:"type" gorm:"embedded;embeddedPrefix:type_"`
+			// Only present on logon messages
+			AircraftInfo struct {
+				ICAO string `json:"icao"`
+			} `json:"ac_info" gorm:"embedded;embeddedPrefix:ac_info_"`
+			HFNPDU struct {
+				Error bool        `json:"err"`
+				Type  HFDLPDUType `json:"type" gorm:"embedded;embeddedPrefix:type_"`
+				// Only present on performance and frequency data messages
+				FlightID string `json:"flight_id"`
+				Position struct {
+					Latitude  float64 `json:"lat"`
+					Longitude float64 `json:"lon"`
+				} `json:"pos" gorm:"embedded;embeddedPrefix:pos_"`
+				ACARS struct {
+					Error                 bool   `json:"err"`
+					CRCOK                 bool   `json:"crc_ok"`
+					More                  bool   `json:"more"`
+					Registration          string `json:"reg" ap:"TailCode"`
+					Mode                  string `json:"mode" ap:"Mode"`
+					Label                 string `json:"label" ap:"Label"`
+					BlockID               string `json:"blk_id"`
+					Acknowledge           any    `json:"ack" gorm:"type:string"`
+					FlightNumber          string `json:"flight" ap:"FlightNumber"`
+					MessageNumber         string `json:"msg_num"`
+					MessageNumberSequence string `json:"msg_num_seq"`
+					Sublabel              string `json:"sublabel" ap:"Sublabel"`
+					MessageText           string `json:"msg_text" ap:"MessageText"`
+				} `json:"acars" gorm:"embedded;embeddedPrefix:acars_"`
+			} `json:"hfnpdu" gorm:"embedded;embeddedPrefix:hfnpdu_"`
+		} `json:"lpdu" gorm:"embedded;embeddedPrefix:lpdu_"`
+	} `json:"hfdl" gorm:"embedded"`
+}
+
+func (h HFDLMessage) Prepare() (result APMessage) {
+	acars := h.HFDL.LPDU.HFNPDU.ACARS
+	// Chop off leading periods
+	acars.Registration, _ = strings.CutPrefix(acars.Registration, ".")
+	// Only logons have the aircraft's ICAO hex
+	hex := strings.ToUpper(h.HFDL.LPDU.AircraftInfo.ICAO)
+	if acars.Registration == "" {
+		acars.Registration = RegistrationFromICAOHex(hex)
+	}
+	result = FormatAsAPMessage(h, h.Name())
+
+	// Sometimes tail numbers lead with periods, chop them off
+	result[ACARSProcessorPrefix+"TailCode"] = strings.TrimPrefix(acars.Registration, ".")
+
+	// Performance data messages have a flight ID but no ACARS content
+	flightNumber := acars.FlightNumber
+	if flightNumber == "" {
+		flightNumber = strings.TrimSpace(h.HFDL.LPDU.HFNPDU.FlightID)
+		result[ACARSProcessorPrefix+"FlightNumber"] = flightNumber
+	}
+
+	// Extra helper or common fields
+	result[ACARSProcessorPrefix+"TrackingLink"] = FlightAwareRoot + acars.Registration
+	result[ACARSProcessorPrefix+"PhotosLink"] = FlightAwarePhotos + acars.Registration
+	result[ACARSProcessorPrefix+"TranslateLink"] = fmt.Sprintf(GoogleTranslateLink, url.QueryEscape(acars.MessageText))
+	result[ACARSProcessorPrefix+"ACARSDramaTailNumberLink"] = fmt.Sprintf(ACARSDramaTailNumberLink, acars.Registration)
+	result[ACARSProcessorPrefix+"UnixTimestamp"] = int64(h.HFDL.Timestamp.UnixTimestamp)
+	result[ACARSProcessorPrefix+"FrequencyMHz"] = float64(h.HFDL.FrequencyHz) / 1000000
+	result[ACARSProcessorPrefix+"From"] = AircraftOrTower(flightNumber)
+	result[ACARSProcessorPrefix+"ICAOHex"] = hex
+	result[ACARSProcessorPrefix+"RegistrationCountry"] = ICAOAddressCountry(hex)
+
+	selectedFields := config.ACARSProcessorSettings.ACARSHub.HFDL.SelectedFields
+	// Remove all but any selected fields
+	if len(selectedFields) > 0 {
+		for field := range result {
+			if !slices.Contains(selectedFields, field) {
+				delete(result, field)
+			}
+		}
+	}
+	return result
+}
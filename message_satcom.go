@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func (s SatcomMessage) Name() string {
+	return "SatcomMessage"
+}
+
+func (s SatcomMessage) GetDefaultFields() APMessage {
+	return SatcomMessage{}.Prepare()
+}
+
+// This is the format JAERO sends for Inmarsat ACARS messages, along with the
+// flat acarsdec-like format acars_router uses for SATCOM messages. Only one
+// of the two will be filled in for any given message.
+type SatcomMessage struct {
+	gorm.Model
+	ProcessingStartedAt  time.Time
+	ProcessingFinishedAt time.Time
+	Processed            bool `gorm:"index"`
+
+	// Common to both formats
+	App struct {
+		Name               string `json:"name"`
+		Version            string `json:"ver"`
+		Proxied            bool   `json:"proxied"`
+		ProxiedBy          string `json:"proxied_by"`
+		ACARSRouterVersion string `json:"acars_router_version"`
+		ACARSRouterUUID    string `json:"acars_router_uuid"`
+	} `json:"app" gorm:"embedded;embeddedPrefix:app_"`
+	// Satellite the message was received from, filled in from the listener
+	// configuration if the decoder doesn't send it.
+	Satellite string `json:"sat" ap:"Satellite"`
+
+	// JAERO
+	Station   string `json:"station"`
+	Timestamp struct {
+		UnixTimestamp int64 `json:"sec"`
+		Microseconds  int64 `json:"usec"`
+	} `json:"t" gorm:"embedded;embeddedPrefix:t_"`
+	ISU struct {
+		AESID                string `json:"aes"`
+		GroundEarthStationID string `json:"ges"`
+		QNumber              string `json:"qno"`
+		ReferenceNumber      string `json:"refno"`
+		ACARS                struct {
+			Mode          string `json:"mode"`
+			Registration  string `json:"reg"`
+			Label         string `json:"label"`
+			BlockID       string `json:"bi"`
+			Acknowledge   any    `json:"ack" gorm:"type:string"`
+			FlightNumber  string `json:"flight"`
+			MessageNumber string `json:"msg_num"`
+			MessageText   string `json:"msg_text"`
+		} `json:"acars" gorm:"embedded;embeddedPrefix:isu_acars_"`
+	} `json:"isu" gorm:"embedded;embeddedPrefix:isu_"`
+
+	// acars_router
+	UnixTimestamp        float64 `json:"timestamp"`
+	StationID            string  `json:"station_id"`
+	FrequencyMHz         float64 `json:"freq"`
+	SignaldBm            float64 `json:"level"`
+	AESID                string  `json:"aes_id"`
+	GroundEarthStationID string  `json:"ges_id"`
+	Mode                 string  `json:"mode"`
+	Label                string  `json:"label"`
+	BlockID              string  `json:"block_id"`
+	Acknowledge          any     `json:"ack" gorm:"type:string"`
+	AircraftTailCode     string  `json:"tail"`
+	FlightNumber         string  `json:"flight"`
+	MessageNumber        string  `json:"msgno"`
+	MessageText          string  `json:"text"`
+}
+
+// Returns true if the message is in the JAERO format
+func (s SatcomMessage) IsJAERO() bool {
+	return s.ISU.AESID != ""
+}
+
+// Returns true if the message has anything identifying it as SATCOM, since
+// the acars_router format otherwise looks just like an ACARS message
+func (s SatcomMessage) IsSatcom() bool {
+	return s.IsJAERO() || s.AESID != ""
+}
+
+func (s SatcomMessage) Prepare() (result APMessage) {
+	// Normalize the two formats so common fields can be filled the same way
+	aes, ges, station := s.AESID, s.GroundEarthStationID, s.StationID
+	tail, label, mode, flight := s.AircraftTailCode, s.Label, s.Mode, s.FlightNumber
+	text, timestamp := s.MessageText, int64(s.UnixTimestamp)
+	if s.IsJAERO() {
+		aes, ges, station = s.ISU.AESID, s.ISU.GroundEarthStationID, s.Station
+		tail, label, mode = s.ISU.ACARS.Registration, s.ISU.ACARS.Label, s.ISU.ACARS.Mode
+		flight, text = s.ISU.ACARS.FlightNumber, s.ISU.ACARS.MessageText
+		timestamp = s.Timestamp.UnixTimestamp
+	}
+	// Chop off leading periods
+	tail = strings.TrimLeft(tail, ".")
+	result = FormatAsAPMessage(s, s.Name())
+
+	result[ACARSProcessorPrefix+"TailCode"] = tail
+	result[ACARSProcessorPrefix+"Label"] = label
+	result[ACARSProcessorPrefix+"Mode"] = mode
+	result[ACARSProcessorPrefix+"FlightNumber"] = flight
+	result[ACARSProcessorPrefix+"MessageText"] = text
+	result[ACARSProcessorPrefix+"StationId"] = station
+	result[ACARSProcessorPrefix+"AESID"] = strings.ToUpper(aes)
+	result[ACARSProcessorPrefix+"GroundEarthStation"] = ges
+
+	// Extra helper or common fields
+	result[ACARSProcessorPrefix+"TrackingLink"] = FlightAwareRoot + tail
+	result[ACARSProcessorPrefix+"PhotosLink"] = FlightAwarePhotos + tail
+	result[ACARSProcessorPrefix+"TranslateLink"] = fmt.Sprintf(GoogleTranslateLink, url.QueryEscape(text))
+	result[ACARSProcessorPrefix+"ACARSDramaTailNumberLink"] = fmt.Sprintf(ACARSDramaTailNumberLink, tail)
+	result[ACARSProcessorPrefix+"UnixTimestamp"] = timestamp
+	result[ACARSProcessorPrefix+"From"] = AircraftOrTower(flight)
+	if s.FrequencyMHz != 0 {
+		result[ACARSProcessorPrefix+"FrequencyMHz"] = s.FrequencyMHz
+		result[ACARSProcessorPrefix+"FrequencyHz"] = int(s.FrequencyMHz * 1000000)
+	}
+	if s.SignaldBm != 0 {
+		result[ACARSProcessorPrefix+"SignalLeveldBm"] = s.SignaldBm
+	}
+
+	selectedFields := config.ACARSProcessorSettings.Listen.SATCOM.SelectedFields
+	// Remove all but any selected fields
+	if len(selectedFields) > 0 {
+		for field := range result {
+			if !slices.Contains(selectedFields, field) {
+				delete(result, field)
+			}
+		}
+	}
+	return result
+}
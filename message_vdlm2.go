@@ -83,14 +83,26 @@ type VDLM2Message struct {
 	} `json:"vdl2" gorm:"embedded"`
 }
 
+// Returns the ICAO hex of the aircraft the message is from or to, empty if
+// neither end is an aircraft
+func (v VDLM2Message) AircraftAddress() string {
+	switch {
+	case v.VDL2.AVLC.Source.Type == "Aircraft":
+		return strings.ToUpper(v.VDL2.AVLC.Source.Address)
+	case v.VDL2.AVLC.Destination.Type == "Aircraft":
+		return strings.ToUpper(v.VDL2.AVLC.Destination.Address)
+	}
+	return ""
+}
+
 func (v VDLM2Message) Prepare() (result APMessage) {
 	// Chop off leading periods
 	v.VDL2.AVLC.ACARS.Registration, _ = strings.CutPrefix(v.VDL2.AVLC.ACARS.Registration, ".")
-	var thumbnail, link string
-	img := getImageByRegistration(v.VDL2.AVLC.ACARS.Registration)
-	if img != nil {
-		thumbnail = img.ThumbnailLarge.Src
-		link = img.Link
+	// Work out the registration from the ICAO hex if the message doesn't have
+	// one and the country assigns them in order
+	hex := v.AircraftAddress()
+	if v.VDL2.AVLC.ACARS.Registration == "" {
+		v.VDL2.AVLC.ACARS.Registration = RegistrationFromICAOHex(hex)
 	}
 	result = FormatAsAPMessage(v, v.Name())
 
@@ -100,13 +112,13 @@ func (v VDLM2Message) Prepare() (result APMessage) {
 	// Extra helper or common fields
 	result[ACARSProcessorPrefix+"TrackingLink"] = FlightAwareRoot + v.VDL2.AVLC.ACARS.Registration
 	result[ACARSProcessorPrefix+"PhotosLink"] = FlightAwarePhotos + v.VDL2.AVLC.ACARS.Registration
-	result[ACARSProcessorPrefix+"ThumbnailLink"] = thumbnail
-	result[ACARSProcessorPrefix+"ImageLink"] = link
 	result[ACARSProcessorPrefix+"TranslateLink"] = fmt.Sprintf(GoogleTranslateLink, url.QueryEscape(v.VDL2.AVLC.ACARS.MessageText))
 	result[ACARSProcessorPrefix+"ACARSDramaTailNumberLink"] = fmt.Sprintf(ACARSDramaTailNumberLink, v.VDL2.AVLC.ACARS.Registration)
 	result[ACARSProcessorPrefix+"UnixTimestamp"] = int64(v.VDL2.Timestamp.UnixTimestamp)
 	result[ACARSProcessorPrefix+"FrequencyMHz"] = float64(v.VDL2.FrequencyHz) / 1000000
 	result[ACARSProcessorPrefix+"From"] = AircraftOrTower(v.VDL2.AVLC.ACARS.FlightNumber)
+	result[ACARSProcessorPrefix+"ICAOHex"] = hex
+	result[ACARSProcessorPrefix+"RegistrationCountry"] = ICAOAddressCountry(hex)
 
 	selectedFields := config.ACARSProcessorSettings.ACARSHub.ACARS.SelectedFields
 	// Remove all but any selected fields
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
@@ -18,9 +20,34 @@ var formatFilterAction = map[bool]string{
 	false: Custom(color.New(color.FgCyan), "finished"),
 }
 
+// Counts of what happened to processed messages, used for summaries
+type ProcessingStats struct {
+	sync.Mutex
+	Finished int
+	Filtered int
+	// Filtered messages by step number and filter name
+	FilteredBy map[string]int
+}
+
+var Stats = ProcessingStats{FilteredBy: map[string]int{}}
+
+func (p *ProcessingStats) Record(filtered bool, step int, name string) {
+	p.Lock()
+	defer p.Unlock()
+	if !filtered {
+		p.Finished++
+		return
+	}
+	p.Filtered++
+	p.FilteredBy[fmt.Sprintf("step %d %s", step, name)]++
+}
+
 // Reads ACARS-Processor messages, filters annotates and
-// sends off to configured receivers
-func HandleAPMessageQueue(apm chan APMessageQeueueItem) {
+// sends off to configured receivers. The returned WaitGroup is done once the
+// channel is closed and every message has been processed.
+func HandleAPMessageQueue(apm chan APMessageQeueueItem) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	apm = ThreadMessages(DeduplicateMessages(ReassembleMessages(apm)))
 	workerCount := config.ACARSProcessorSettings.ACARSHub.MaxConcurrentRequests
 	if workerCount <= 0 {
 		workerCount = 1 // fallback safety
@@ -28,6 +55,7 @@ func HandleAPMessageQueue(apm chan APMessageQeueueItem) {
 
 	// Worker function
 	worker := func() {
+		defer wg.Done()
 		for message := range apm {
 			start := time.Now()
 			var filter bool
@@ -80,6 +108,7 @@ func HandleAPMessageQueue(apm chan APMessageQeueueItem) {
 			} else {
 				textPreview = Content("message with blank or empty message text")
 			}
+			Stats.Record(filter, exitStep, name)
 			log.Info(textPreview,
 				Content(" was "),
 				formatFilterAction[filter],
@@ -89,20 +118,38 @@ func HandleAPMessageQueue(apm chan APMessageQeueueItem) {
 				Note("%.2f seconds", time.Since(start).Seconds()),
 				Content(" to process and was ingested "),
 				Note("%.2f seconds ago", time.Since(msgts).Seconds()))
-			if !(reflect.DeepEqual(message.ACARSMessage, ACARSMessage{})) {
-				message.ACARSMessage.ProcessingFinishedAt = time.Now()
-				message.ACARSMessage.Processed = true
-				db.Updates(&message.ACARSMessage)
-			}
-			if !(reflect.DeepEqual(message.VDLM2Message, VDLM2Message{})) {
-				message.VDLM2Message.ProcessingFinishedAt = time.Now()
-				message.VDLM2Message.Processed = true
-				db.Updates(&message.VDLM2Message)
-			}
+			message.MarkProcessed()
 		}
 	}
 	// Start workers (they run forever, waiting for channel messages)
+	wg.Add(workerCount)
 	for i := 0; i < workerCount; i++ {
 		go worker()
 	}
+	return &wg
+}
+
+// Saves that the message is done being processed so it isn't loaded again at
+// startup
+func (m APMessageQeueueItem) MarkProcessed() {
+	if !(reflect.DeepEqual(m.ACARSMessage, ACARSMessage{})) {
+		m.ACARSMessage.ProcessingFinishedAt = time.Now()
+		m.ACARSMessage.Processed = true
+		db.Updates(&m.ACARSMessage)
+	}
+	if !(reflect.DeepEqual(m.VDLM2Message, VDLM2Message{})) {
+		m.VDLM2Message.ProcessingFinishedAt = time.Now()
+		m.VDLM2Message.Processed = true
+		db.Updates(&m.VDLM2Message)
+	}
+	if !(reflect.DeepEqual(m.HFDLMessage, HFDLMessage{})) {
+		m.HFDLMessage.ProcessingFinishedAt = time.Now()
+		m.HFDLMessage.Processed = true
+		db.Updates(&m.HFDLMessage)
+	}
+	if !(reflect.DeepEqual(m.SatcomMessage, SatcomMessage{})) {
+		m.SatcomMessage.ProcessingFinishedAt = time.Now()
+		m.SatcomMessage.Processed = true
+		db.Updates(&m.SatcomMessage)
+	}
 }
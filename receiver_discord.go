@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"reflect"
 	"regexp"
 	"slices"
@@ -13,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	hue "codeberg.org/tyzbit/huenique"
 	log "github.com/sirupsen/logrus"
@@ -21,6 +23,8 @@ import (
 const (
 	defaultEmbedColor = "1150122" // cyan, base 10
 	footer            = "-# Message generated with [acars-processor](<https://github.com/tyzbit/acars-processor>)"
+	// How long to wait for Discord to take a message
+	discordWebhookTimeout = 30 * time.Second
 )
 
 type DiscordReceiver struct {
@@ -42,11 +46,23 @@ type DiscordReceiver struct {
 	FormatTimestamps bool `jsonschema:"default=true" default:"true"`
 	// Go template for the message. Insert fields like this: `{{ index . "ACARSProcessor.TailCode" }}`
 	MessageGoTemplate string `jsonschema:"example=New message from aircraft! Message is {{ index . \"ACARSProcessor.MessageText\" }}" default:"New message from aircraft! Message is: {{ index . \"ACARSMessage.MessageText\" }}"`
+	// Post each conversation (see Threading in ACARSProcessorSettings) in its own thread. The webhook must be for a forum channel.
+	PostConversationsInThreads bool `jsonschema:"default=false" default:"false"`
+	// Show the decoded weather from the Weather annotator instead of the raw message text when there is some. Only used without MessageGoTemplate.
+	ShowDecodedWeather bool `jsonschema:"default=false" default:"false"`
 }
 
 type DiscordWebhookMessage struct {
 	Content string         `json:"content,omitempty"`
 	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+	// Starts a new thread with this name, only for forum channels
+	ThreadName string `json:"thread_name,omitempty"`
+}
+
+// The part of the response to a webhook call with wait=true we need
+type DiscordWebhookResponse struct {
+	// The thread ID if the message was posted in a thread
+	ChannelID string `json:"channel_id"`
 }
 
 type DiscordEmbed struct {
@@ -118,10 +134,23 @@ func (d DiscordReceiver) Send(m APMessage) error {
 		}
 		content = string(br)
 	} else {
+		var weather string
+		if d.ShowDecodedWeather {
+			weather = GetAPMessageCommonFieldAsString(m, "Weather.Summary")
+		}
 		for _, key := range keys {
 			textField := r.MatchString(key)
 			linkField := l.MatchString(key)
 			v := m[key]
+			if weather != "" {
+				// It's shown in place of the text instead
+				if key == WeatherPrefix+".Summary" {
+					continue
+				}
+				if strings.HasSuffix(key, "MessageText") {
+					v = weather
+				}
+			}
 			if d.FormatText &&
 				v != "" && textField {
 				v = fmt.Sprintf("```%s```", v)
@@ -148,7 +177,7 @@ func (d DiscordReceiver) Send(m APMessage) error {
 		content = content + footer
 	}
 	if d.Embed {
-		var url, transmitter, thumbnail, embedColorString string
+		var url, transmitter, via, thumbnail, embedColorString string
 		var embedColorValue int
 
 		for _, key := range keys {
@@ -162,6 +191,9 @@ func (d DiscordReceiver) Send(m APMessage) error {
 			if ts, _ := regexp.Compile(".*[Ff]rom$"); ts.Match([]byte(key)) {
 				transmitter = " from " + v
 			}
+			if key == ACARSProcessorPrefix+"Satellite" && v != "" {
+				via = " via " + v
+			}
 			if slices.Contains(d.EmbedColorFacetFields, key) {
 				embedColorString = embedColorString + v
 			}
@@ -182,6 +214,15 @@ func (d DiscordReceiver) Send(m APMessage) error {
 			}
 		}
 
+		// Name the airline if the Airline annotator found it
+		if airline := GetAPMessageCommonFieldAsString(m, "AirlineName"); airline != "" {
+			flight := GetAPMessageCommonFieldAsString(m, "FlightNumberIATA")
+			if flight == "" {
+				flight = GetAPMessageCommonFieldAsString(m, "FlightNumberICAO")
+			}
+			transmitter = " from " + strings.TrimSpace(airline+" "+flight)
+		}
+
 		var color string
 		if d.EmbedColorFacetFields != nil {
 			color = fmt.Sprintf("%d", hue.GetRGBValueForString(embedColorString))
@@ -190,7 +231,7 @@ func (d DiscordReceiver) Send(m APMessage) error {
 			color = fmt.Sprintf("%d", hue.PickRGBValueForInt(embedColorValue))
 		}
 		embeds = append(embeds, DiscordEmbed{
-			Title:       fmt.Sprintf("ACARS Message%s", transmitter),
+			Title:       fmt.Sprintf("ACARS Message%s%s", transmitter, via),
 			Description: content,
 			Color:       color,
 			URL:         url,
@@ -211,11 +252,37 @@ func (d DiscordReceiver) Send(m APMessage) error {
 		Embeds: embeds,
 	}
 
+	webhookURL := d.URL
+	var post ConversationPost
+	threadID := ConversationThreadID(m)
+	if d.PostConversationsInThreads && threadID != 0 {
+		defer LockConversationPost(threadID)()
+		post = FindConversationPost(threadID, ConversationReceiverKey(d.Name(), d.URL))
+		u, err := url.Parse(d.URL)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		// Discord only returns the message (and its thread) if we wait for it
+		q.Set("wait", "true")
+		if post.PostID != "" {
+			q.Set("thread_id", post.PostID)
+		} else {
+			message.ThreadName = fmt.Sprintf("Conversation with %s",
+				GetAPMessageCommonFieldAsString(m, "TailCode"))
+			if flight := GetAPMessageCommonFieldAsString(m, "FlightNumber"); flight != "" {
+				message.ThreadName += fmt.Sprintf(" (%s)", flight)
+			}
+		}
+		u.RawQuery = q.Encode()
+		webhookURL = u.String()
+	}
+
 	err := json.NewEncoder(buff).Encode(message)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest("POST", d.URL, buff)
+	req, err := http.NewRequest("POST", webhookURL, buff)
 	if err != nil {
 		return err
 	}
@@ -224,7 +291,7 @@ func (d DiscordReceiver) Send(m APMessage) error {
 	req.Header.Add("Content-Type", "application/json")
 
 	log.Debug(Aside("%s: calling receiver", d.Name()))
-	client := &http.Client{}
+	client := &http.Client{Timeout: discordWebhookTimeout}
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -238,5 +305,17 @@ func (d DiscordReceiver) Send(m APMessage) error {
 	if response := string(body); response != "" {
 		log.Debug(Aside("%s: api returned %s", d.Name(), response))
 	}
+	// Save the new thread so the rest of the conversation goes in it
+	if message.ThreadName != "" {
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("unable to start thread, discord returned status %d", resp.StatusCode)
+		}
+		var r DiscordWebhookResponse
+		if err := json.Unmarshal(body, &r); err != nil {
+			return fmt.Errorf("unable to read thread from discord response: %w", err)
+		}
+		post.PostID = r.ChannelID
+		SaveConversationPost(post)
+	}
 	return err
 }
@@ -9,11 +9,15 @@ import (
 	"reflect"
 	"sort"
 	"text/template"
+	"time"
 
 	"github.com/mattn/go-mastodon"
 	log "github.com/sirupsen/logrus"
 )
 
+// How long to wait for the server to take a post
+const mastodonPostTimeout = 30 * time.Second
+
 type MastodonReceiver struct {
 	Module
 	Receiver
@@ -29,6 +33,8 @@ type MastodonReceiver struct {
 	Visibility string `jsonschema:"required,example=public,example=unlisted,example=private,example=direct,default=unlisted" default:"unlisted"`
 	// Go template for the post. Insert fields like this: `{{ index . "ACARSProcessor.TailCode" }}`
 	PostGoTemplate string `jsonschema:"example=New message from aircraft! Message is {{ index . \"ACARSProcessor.MessageText\" }}" default:"New message from aircraft! Message is: {{ index . \"ACARSMessage.MessageText\" }}"`
+	// Post each message in a conversation (see Threading in ACARSProcessorSettings) as a reply to the one before it.
+	ReplyToConversations bool `jsonschema:"default=false" default:"false"`
 }
 
 func (mr MastodonReceiver) Name() string {
@@ -77,11 +83,25 @@ func (mr MastodonReceiver) Send(m APMessage) error {
 		Status:     status,
 		Visibility: mr.Visibility,
 	}
+	var conversation ConversationPost
+	threadID := ConversationThreadID(m)
+	if mr.ReplyToConversations && threadID != 0 {
+		defer LockConversationPost(threadID)()
+		conversation = FindConversationPost(threadID, ConversationReceiverKey(mr.Name(), mr.Server+mr.AccessToken))
+		toot.InReplyToID = mastodon.ID(conversation.PostID)
+	}
 	log.Debug(Aside("%s: calling receiver", mr.Name()))
-	post, err := mstdn.PostStatus(context.Background(), &toot)
+	ctx, cancel := context.WithTimeout(context.Background(), mastodonPostTimeout)
+	defer cancel()
+	post, err := mstdn.PostStatus(ctx, &toot)
 	if err != nil {
 		return fmt.Errorf("posting failed, err: %s", err)
 	}
+	// The next message in the conversation replies to this one
+	if mr.ReplyToConversations && threadID != 0 {
+		conversation.PostID = string(post.ID)
+		SaveConversationPost(conversation)
+	}
 	postBytes, _ := json.Marshal(post)
 	log.Debug(Aside("%s: status posted, status info: %s", mr.Name(), string(postBytes)))
 	return nil
@@ -44,6 +44,30 @@ func (vc VDLM2ConnectionConfig) JSONSchemaExtend(j *jsonschema.Schema) {
 	j.Properties.Set("SelectedFields", s)
 }
 
+// These are called when jsonschema Reflects, so we don't need to call these.
+func (hc HFDLConnectionConfig) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for hfdl config type"))
+		return
+	}
+	f := hc.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+// These are called when jsonschema Reflects, so we don't need to call these.
+func (sc SatcomListenerConfig) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for satcom config type"))
+		return
+	}
+	f := sc.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
 func (t Tar1090Annotator) JSONSchemaExtend(j *jsonschema.Schema) {
 	s, ok := j.Properties.Get("SelectedFields")
 	if !ok {
@@ -77,6 +101,105 @@ func (o OllamaAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
 	j.Properties.Set("SelectedFields", s)
 }
 
+func (d DecoderAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for decoder annotator config type"))
+		return
+	}
+	f := d.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+func (l LabelAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for label annotator config type"))
+		return
+	}
+	f := l.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+func (a AirlineAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for airline annotator config type"))
+		return
+	}
+	f := a.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+func (a AircraftDatabaseAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for aircraft database annotator config type"))
+		return
+	}
+	f := a.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+func (a ReadsbAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for readsb annotator config type"))
+		return
+	}
+	f := a.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+func (a AirportAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for airport annotator config type"))
+		return
+	}
+	f := a.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+func (a WeatherAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for weather annotator config type"))
+		return
+	}
+	f := a.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+func (a ClearanceAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for clearance annotator config type"))
+		return
+	}
+	f := a.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
+func (a PlanespottersAnnotator) JSONSchemaExtend(j *jsonschema.Schema) {
+	s, ok := j.Properties.Get("SelectedFields")
+	if !ok {
+		log.Error(Attention("couldn't get selectedfields for planespotters annotator config type"))
+		return
+	}
+	f := a.GetDefaultFields()
+	s.Examples = append(s.Examples, f)
+	j.Properties.Set("SelectedFields", s)
+}
+
 func GenerateSchema() (schemaUpdated bool) {
 
 	log.Info(Content("Generating %s", schemaFilePath))
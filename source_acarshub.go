@@ -7,6 +7,7 @@ import (
 	"net"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +21,10 @@ var (
 type APMessageQeueueItem struct {
 	ACARSMessage
 	VDLM2Message
+	HFDLMessage
+	// The acars_router SATCOM format shares keys with ACARS messages, so keep
+	// it from being flattened if this is ever encoded.
+	SatcomMessage `json:"satcom"`
 	APMessage
 }
 
@@ -41,6 +46,24 @@ func (vc VDLM2ConnectionConfig) GetDefaultFields() (s []string) {
 	return s
 }
 
+func (hc HFDLConnectionConfig) GetDefaultFields() (s []string) {
+	h := HFDLMessage{}.Prepare()
+	for f := range h {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
+func (sc SatcomListenerConfig) GetDefaultFields() (s []string) {
+	m := SatcomMessage{}.Prepare()
+	for f := range m {
+		s = append(s, f)
+	}
+	sort.Strings(s)
+	return s
+}
+
 // Connects to ACARS and starts listening to messages
 func SubscribeToACARSHub() {
 	launched := false
@@ -52,16 +75,23 @@ func SubscribeToACARSHub() {
 		go ReadACARSHubVDLM2Messages()
 		launched = true
 	}
+	if config.ACARSProcessorSettings.ACARSHub.HFDL.Host != "" && config.ACARSProcessorSettings.ACARSHub.HFDL.Port != 0 {
+		go ReadACARSHubHFDLMessages()
+		launched = true
+	}
+	launched = SubscribeToListeners() || launched
+	launched = SubscribeToHTTPIngest() || launched
+	launched = SubscribeToMQTT() || launched
 	go HandleAPMessageQueue(APMessageQueue)
 	if !launched {
-		log.Warn(Attention("no acarshub subscribers set, please check configuration (%s)()", configFilePath))
+		log.Warn(Attention("no acarshub subscribers, listeners, http ingest or mqtt set, please check configuration (%s)()", configFilePath))
 	} else {
 		log.Debug(Aside("launched acarshub subscribers"))
 	}
 }
 
 func ReadACARSHubACARSMessages() {
-	address := fmt.Sprintf("%s:%d", config.ACARSProcessorSettings.ACARSHub.ACARS.Host, config.ACARSProcessorSettings.ACARSHub.ACARS.Port)
+	address := net.JoinHostPort(config.ACARSProcessorSettings.ACARSHub.ACARS.Host, strconv.Itoa(config.ACARSProcessorSettings.ACARSHub.ACARS.Port))
 	for {
 		log.Debug(Aside("connecting to "), Note(config.ACARSProcessorSettings.ACARSHub.ACARS.Host), Aside(" on acars json port "), Note(fmt.Sprint(config.ACARSProcessorSettings.ACARSHub.ACARS.Port)))
 		s, err := net.Dial("tcp", address)
@@ -80,29 +110,12 @@ func ReadACARSHubACARSMessages() {
 				log.Error(Attention("error decoding acars message: %v", err))
 				break
 			}
-			log.Info(Content("new acars message received ending in \""),
-				Note(Last20Characters(next.MessageText)),
-				Content("\""))
 			if (next == ACARSMessage{}) {
 				log.Error(Attention("json message did not match expected structure, we got: "),
 					Emphasised("%+v", next))
 				continue
-			} else {
-				queueLength := len(APMessageQueue)
-				nextap := next.Prepare()
-				if msgJson, err := json.Marshal(next); err == nil {
-					log.Debug(Emphasised("new acars message content "),
-						Note("(%d already in queue)", queueLength),
-						Content(": "),
-						Aside("%s", strings.ReplaceAll(string(msgJson), "\n", "\t")))
-				}
-				db.Create(&next)
-				APMessageQueue <- APMessageQeueueItem{
-					ACARSMessage: next,
-					APMessage:    nextap,
-				}
-				continue
 			}
+			QueueACARSMessage(next, APMessageQueue)
 		}
 
 		log.Warn(Attention("acars handler exited, reconnecting"))
@@ -112,7 +125,7 @@ func ReadACARSHubACARSMessages() {
 }
 
 func ReadACARSHubVDLM2Messages() {
-	address := fmt.Sprintf("%s:%d", config.ACARSProcessorSettings.ACARSHub.VDLM2.Host, config.ACARSProcessorSettings.ACARSHub.VDLM2.Port)
+	address := net.JoinHostPort(config.ACARSProcessorSettings.ACARSHub.VDLM2.Host, strconv.Itoa(config.ACARSProcessorSettings.ACARSHub.VDLM2.Port))
 	for {
 		log.Debug(Aside("connecting to "), Note(config.ACARSProcessorSettings.ACARSHub.VDLM2.Host), Aside(" on vdlm2 json port "), Note(fmt.Sprint(config.ACARSProcessorSettings.ACARSHub.VDLM2.Port)))
 		s, err := net.Dial("tcp", address)
@@ -131,28 +144,11 @@ func ReadACARSHubVDLM2Messages() {
 				log.Error(Attention("error decoding vdlm2 message: %v", err))
 				break
 			}
-			log.Info(Content("new vdlm2 message received ending in \""),
-				Note(Last20Characters(next.VDL2.AVLC.ACARS.MessageText)),
-				Content("\""))
 			if (next == VDLM2Message{}) {
 				log.Error(Attention("json message did not match expected structure, we got: %+v", next))
 				continue
-			} else {
-				queueLength := len(APMessageQueue)
-				nextap := next.Prepare()
-				if msgJson, err := json.Marshal(next); err == nil {
-					log.Debug(Emphasised("new vdlm2 message content "),
-						Note("(%d already in queue)", queueLength),
-						Content(": "),
-						Aside("%s", strings.ReplaceAll(string(msgJson), "\n", "\t")))
-				}
-				db.Create(&next)
-				APMessageQueue <- APMessageQeueueItem{
-					VDLM2Message: next,
-					APMessage:    nextap,
-				}
-				continue
 			}
+			QueueVDLM2Message(next, APMessageQueue)
 		}
 
 		log.Warn(Attention("vdlm2 handler exited, reconnecting"))
@@ -161,6 +157,123 @@ func ReadACARSHubVDLM2Messages() {
 	}
 }
 
+func ReadACARSHubHFDLMessages() {
+	address := net.JoinHostPort(config.ACARSProcessorSettings.ACARSHub.HFDL.Host, strconv.Itoa(config.ACARSProcessorSettings.ACARSHub.HFDL.Port))
+	for {
+		log.Debug(Aside("connecting to "), Note(config.ACARSProcessorSettings.ACARSHub.HFDL.Host), Aside(" on hfdl json port "), Note(fmt.Sprint(config.ACARSProcessorSettings.ACARSHub.HFDL.Port)))
+		s, err := net.Dial("tcp", address)
+		if err != nil {
+			log.Error(Attention("error connecting to hfdl json: %v", err))
+			time.Sleep(time.Second * 1)
+			continue
+		}
+		log.Info(Success("connected to acarshub hfdl json port successfully"))
+		readJson := json.NewDecoder(io.Reader(s))
+		log.Debug(Aside("handling hfdl json messages"))
+		for {
+			var next HFDLMessage
+			if err := readJson.Decode(&next); err != nil {
+				// Might have connection issues, exit to reconnect
+				log.Error(Attention("error decoding hfdl message: %v", err))
+				break
+			}
+			if (next == HFDLMessage{}) {
+				log.Error(Attention("json message did not match expected structure, we got: %+v", next))
+				continue
+			}
+			QueueHFDLMessage(next, APMessageQueue)
+		}
+
+		log.Warn(Attention("hfdl handler exited, reconnecting"))
+		s.Close()
+		time.Sleep(time.Second * 1)
+	}
+}
+
+// Saves an ACARS message to the database and adds it to the queue for
+// processing
+func QueueACARSMessage(next ACARSMessage, queue chan APMessageQeueueItem) {
+	log.Info(Content("new acars message received ending in \""),
+		Note(Last20Characters(next.MessageText)),
+		Content("\""))
+	queueLength := len(queue)
+	nextap := next.Prepare()
+	if msgJson, err := json.Marshal(next); err == nil {
+		log.Debug(Emphasised("new acars message content "),
+			Note("(%d already in queue)", queueLength),
+			Content(": "),
+			Aside("%s", strings.ReplaceAll(string(msgJson), "\n", "\t")))
+	}
+	db.Create(&next)
+	queue <- APMessageQeueueItem{
+		ACARSMessage: next,
+		APMessage:    nextap,
+	}
+}
+
+// Saves a VDLM2 message to the database and adds it to the queue for
+// processing
+func QueueVDLM2Message(next VDLM2Message, queue chan APMessageQeueueItem) {
+	log.Info(Content("new vdlm2 message received ending in \""),
+		Note(Last20Characters(next.VDL2.AVLC.ACARS.MessageText)),
+		Content("\""))
+	queueLength := len(queue)
+	nextap := next.Prepare()
+	if msgJson, err := json.Marshal(next); err == nil {
+		log.Debug(Emphasised("new vdlm2 message content "),
+			Note("(%d already in queue)", queueLength),
+			Content(": "),
+			Aside("%s", strings.ReplaceAll(string(msgJson), "\n", "\t")))
+	}
+	db.Create(&next)
+	queue <- APMessageQeueueItem{
+		VDLM2Message: next,
+		APMessage:    nextap,
+	}
+}
+
+// Saves an HFDL message to the database and adds it to the queue for
+// processing
+func QueueHFDLMessage(next HFDLMessage, queue chan APMessageQeueueItem) {
+	log.Info(Content("new hfdl message received ending in \""),
+		Note(Last20Characters(next.HFDL.LPDU.HFNPDU.ACARS.MessageText)),
+		Content("\""))
+	queueLength := len(queue)
+	nextap := next.Prepare()
+	if msgJson, err := json.Marshal(next); err == nil {
+		log.Debug(Emphasised("new hfdl message content "),
+			Note("(%d already in queue)", queueLength),
+			Content(": "),
+			Aside("%s", strings.ReplaceAll(string(msgJson), "\n", "\t")))
+	}
+	db.Create(&next)
+	queue <- APMessageQeueueItem{
+		HFDLMessage: next,
+		APMessage:   nextap,
+	}
+}
+
+// Saves a SATCOM message to the database and adds it to the queue for
+// processing
+func QueueSatcomMessage(next SatcomMessage, queue chan APMessageQeueueItem) {
+	nextap := next.Prepare()
+	log.Info(Content("new satcom message received ending in \""),
+		Note(Last20Characters(GetAPMessageCommonFieldAsString(nextap, "MessageText"))),
+		Content("\""))
+	queueLength := len(queue)
+	if msgJson, err := json.Marshal(next); err == nil {
+		log.Debug(Emphasised("new satcom message content "),
+			Note("(%d already in queue)", queueLength),
+			Content(": "),
+			Aside("%s", strings.ReplaceAll(string(msgJson), "\n", "\t")))
+	}
+	db.Create(&next)
+	queue <- APMessageQeueueItem{
+		SatcomMessage: next,
+		APMessage:     nextap,
+	}
+}
+
 // Returns Tower if the flightNumber has any text
 func AircraftOrTower(fightNumber string) string {
 	if b, _ := regexp.MatchString("\\S+", fightNumber); b {
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	httpIngestPath            = "/ingest"
+	defaultHTTPIngestMaxBytes = 10 * 1024 * 1024
+)
+
+// Status of a single pushed message
+type HTTPIngestResult struct {
+	Index    int    `json:"index"`
+	Accepted bool   `json:"accepted"`
+	Type     string `json:"type,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type HTTPIngestResponse struct {
+	Accepted int                `json:"accepted"`
+	Rejected int                `json:"rejected"`
+	Results  []HTTPIngestResult `json:"results,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// Starts the HTTP ingest server if it's configured, returns true if it was
+// started.
+func SubscribeToHTTPIngest() (launched bool) {
+	hc := config.ACARSProcessorSettings.HTTPIngest
+	if hc.Port == 0 {
+		return false
+	}
+	address := net.JoinHostPort(hc.Host, strconv.Itoa(hc.Port))
+	mux := http.NewServeMux()
+	mux.HandleFunc(httpIngestPath, hc.ServeIngest)
+	go func() {
+		log.Info(Success("listening for messages on http://%s%s", address, httpIngestPath))
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.Error(Attention("http ingest server stopped: %s", err))
+		}
+	}()
+	return true
+}
+
+// Accepts a single message or an array of messages (ACARS, VDLM2, HFDL or
+// SATCOM, in the same JSON the decoders send) and queues them.
+func (hc HTTPIngestConfig) ServeIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeHTTPIngestResponse(w, http.StatusMethodNotAllowed, HTTPIngestResponse{Error: "only POST is supported"})
+		return
+	}
+	if hc.BearerToken != "" {
+		want := []byte("Bearer " + hc.BearerToken)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeHTTPIngestResponse(w, http.StatusUnauthorized, HTTPIngestResponse{Error: "missing or incorrect bearer token"})
+			return
+		}
+	}
+
+	maxBytes := hc.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultHTTPIngestMaxBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeHTTPIngestResponse(w, status, HTTPIngestResponse{Error: fmt.Sprintf("error reading request: %s", err)})
+		return
+	}
+
+	items, err := SplitHTTPIngestBody(body)
+	if err != nil {
+		writeHTTPIngestResponse(w, http.StatusBadRequest, HTTPIngestResponse{Error: err.Error()})
+		return
+	}
+
+	response := HTTPIngestResponse{Results: make([]HTTPIngestResult, 0, len(items))}
+	for i, item := range items {
+		result := HTTPIngestResult{Index: i}
+		next, err := ParseSourceMessage(item)
+		if err != nil {
+			result.Error = err.Error()
+			response.Rejected++
+		} else {
+			QueueSourceMessage(next, APMessageQueue)
+			result.Accepted = true
+			result.Type = next.Name()
+			response.Accepted++
+		}
+		response.Results = append(response.Results, result)
+	}
+	log.Debug(Aside("http ingest from %s: %d accepted, %d rejected", r.RemoteAddr, response.Accepted, response.Rejected))
+
+	status := http.StatusOK
+	if response.Accepted == 0 {
+		status = http.StatusBadRequest
+	}
+	writeHTTPIngestResponse(w, status, response)
+}
+
+// Returns each message in the body, which can be one object or an array of
+// them
+func SplitHTTPIngestBody(body []byte) (items []json.RawMessage, err error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("request body was empty")
+	}
+	if body[0] != '[' {
+		return []json.RawMessage{body}, nil
+	}
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, fmt.Errorf("error unmarshalling array: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("array had no messages in it")
+	}
+	return items, nil
+}
+
+func writeHTTPIngestResponse(w http.ResponseWriter, status int, response HTTPIngestResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Warn(Attention("error writing http ingest response: %s", err))
+	}
+}
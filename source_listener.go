@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Largest possible UDP payload, decoders send one or more JSON objects per
+// datagram.
+const maxUDPDatagramSize = 65535
+
+// Starts any configured UDP and TCP listeners, returns true if at least one
+// was started.
+func SubscribeToListeners() (launched bool) {
+	lc := config.ACARSProcessorSettings.Listen
+	if lc.ACARS.UDPPort != 0 {
+		go ListenForUDPMessages("acars", lc.ACARS.Address(lc.ACARS.UDPPort), DecodeACARSMessages)
+		launched = true
+	}
+	if lc.ACARS.TCPPort != 0 {
+		go ListenForTCPMessages("acars", lc.ACARS.Address(lc.ACARS.TCPPort), DecodeACARSMessages)
+		launched = true
+	}
+	if lc.VDLM2.UDPPort != 0 {
+		go ListenForUDPMessages("vdlm2", lc.VDLM2.Address(lc.VDLM2.UDPPort), DecodeVDLM2Messages)
+		launched = true
+	}
+	if lc.VDLM2.TCPPort != 0 {
+		go ListenForTCPMessages("vdlm2", lc.VDLM2.Address(lc.VDLM2.TCPPort), DecodeVDLM2Messages)
+		launched = true
+	}
+	if lc.HFDL.UDPPort != 0 {
+		go ListenForUDPMessages("hfdl", lc.HFDL.Address(lc.HFDL.UDPPort), DecodeHFDLMessages)
+		launched = true
+	}
+	if lc.HFDL.TCPPort != 0 {
+		go ListenForTCPMessages("hfdl", lc.HFDL.Address(lc.HFDL.TCPPort), DecodeHFDLMessages)
+		launched = true
+	}
+	if lc.SATCOM.UDPPort != 0 {
+		go ListenForUDPMessages("satcom", lc.SATCOM.Address(lc.SATCOM.UDPPort), DecodeSatcomMessages)
+		launched = true
+	}
+	if lc.SATCOM.TCPPort != 0 {
+		go ListenForTCPMessages("satcom", lc.SATCOM.Address(lc.SATCOM.TCPPort), DecodeSatcomMessages)
+		launched = true
+	}
+	return launched
+}
+
+// Returns host:port for the listener, listening on all addresses if no host
+// was set.
+func (l ListenerConnectionConfig) Address(port int) string {
+	return net.JoinHostPort(l.Host, strconv.Itoa(port))
+}
+
+// Listens for datagrams and decodes each one with decode
+func ListenForUDPMessages(name, address string, decode func(*json.Decoder) error) {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		log.Error(Attention("unable to listen for %s json on udp %s: %v", name, address, err))
+		return
+	}
+	defer conn.Close()
+	log.Info(Success("listening for %s json on udp %s", name, address))
+	buf := make([]byte, maxUDPDatagramSize)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Error(Attention("error reading %s datagram: %v", name, err))
+			continue
+		}
+		// Copy the datagram so the buffer can be reused right away
+		datagram := bytes.Clone(buf[:n])
+		if err := decode(json.NewDecoder(bytes.NewReader(datagram))); err != nil {
+			log.Error(Attention("error decoding %s datagram from %s: %v", name, from, err))
+		}
+	}
+}
+
+// Accepts connections and decodes the stream from each one with decode
+func ListenForTCPMessages(name, address string, decode func(*json.Decoder) error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Error(Attention("unable to listen for %s json on tcp %s: %v", name, address, err))
+		return
+	}
+	defer l.Close()
+	log.Info(Success("listening for %s json on tcp %s", name, address))
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Error(Attention("error accepting %s connection: %v", name, err))
+			continue
+		}
+		go func(c net.Conn) {
+			defer c.Close()
+			log.Info(Success("%s feeder connected from %s", name, c.RemoteAddr()))
+			if err := decode(json.NewDecoder(c)); err != nil {
+				log.Error(Attention("error decoding %s message from %s: %v", name, c.RemoteAddr(), err))
+			}
+			log.Info(Note("%s feeder %s disconnected", name, c.RemoteAddr()))
+		}(conn)
+	}
+}
+
+// Decodes and queues ACARS messages until the decoder runs out of input
+func DecodeACARSMessages(d *json.Decoder) error {
+	for {
+		var next ACARSMessage
+		if err := d.Decode(&next); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if (next == ACARSMessage{}) {
+			log.Error(Attention("json message did not match expected structure, we got: "),
+				Emphasised("%+v", next))
+			continue
+		}
+		QueueACARSMessage(next, APMessageQueue)
+	}
+}
+
+// Decodes and queues VDLM2 messages until the decoder runs out of input
+func DecodeVDLM2Messages(d *json.Decoder) error {
+	for {
+		var next VDLM2Message
+		if err := d.Decode(&next); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if (next == VDLM2Message{}) {
+			log.Error(Attention("json message did not match expected structure, we got: %+v", next))
+			continue
+		}
+		QueueVDLM2Message(next, APMessageQueue)
+	}
+}
+
+// Decodes and queues HFDL messages until the decoder runs out of input
+func DecodeHFDLMessages(d *json.Decoder) error {
+	for {
+		var next HFDLMessage
+		if err := d.Decode(&next); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if (next == HFDLMessage{}) {
+			log.Error(Attention("json message did not match expected structure, we got: %+v", next))
+			continue
+		}
+		QueueHFDLMessage(next, APMessageQueue)
+	}
+}
+
+// Decodes and queues SATCOM messages until the decoder runs out of input
+func DecodeSatcomMessages(d *json.Decoder) error {
+	for {
+		var next SatcomMessage
+		if err := d.Decode(&next); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if (next == SatcomMessage{}) {
+			log.Error(Attention("json message did not match expected structure, we got: %+v", next))
+			continue
+		}
+		if next.Satellite == "" {
+			next.Satellite = config.ACARSProcessorSettings.Listen.SATCOM.Satellite
+		}
+		QueueSatcomMessage(next, APMessageQueue)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+const mqttConnectRetryInterval = 10 * time.Second
+
+// Connects to the configured MQTT broker and subscribes to the configured
+// topics, returns true if it was started.
+func SubscribeToMQTT() (launched bool) {
+	mc := config.ACARSProcessorSettings.MQTT
+	if mc.Broker == "" {
+		return false
+	}
+	if len(mc.Topics) == 0 {
+		log.Warn(Attention("mqtt broker %s is set but no topics are, not subscribing", mc.Broker))
+		return false
+	}
+	if mc.QoS > 2 {
+		log.Fatal(Attention("mqtt QoS must be 0, 1 or 2, got %d", mc.QoS))
+	}
+	topics := make(map[string]byte, len(mc.Topics))
+	for _, topic := range mc.Topics {
+		topics[topic] = mc.QoS
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(mc.Broker).
+		SetClientID(mc.ClientID).
+		SetUsername(mc.Username).
+		SetPassword(mc.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(mqttConnectRetryInterval)
+	// Subscriptions don't survive a clean session reconnect, so (re)subscribe
+	// every time the connection is made
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		log.Info(Success("connected to mqtt broker %s", mc.Broker))
+		token := c.SubscribeMultiple(topics, HandleMQTTMessage)
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Error(Attention("unable to subscribe to mqtt topics %v: %s", mc.Topics, err))
+			return
+		}
+		log.Info(Success("subscribed to mqtt topics %v with QoS %d", mc.Topics, mc.QoS))
+	})
+	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
+		log.Warn(Attention("lost connection to mqtt broker %s: %s, reconnecting", mc.Broker, err))
+	})
+
+	// With connect retry on, this returns right away and keeps trying in the
+	// background
+	mqtt.NewClient(opts).Connect()
+	return true
+}
+
+// Works out what kind of message the payload is and queues it
+func HandleMQTTMessage(c mqtt.Client, m mqtt.Message) {
+	next, err := ParseSourceMessage(m.Payload())
+	if err != nil {
+		log.Warn(Attention("skipping message from mqtt topic %s: %s", m.Topic(), err))
+		return
+	}
+	QueueSourceMessage(next, APMessageQueue)
+}
@@ -0,0 +1,97 @@
+package main
+
+import "testing"
+
+// An MQTT message without a broker behind it
+type testMQTTMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m testMQTTMessage) Duplicate() bool   { return false }
+func (m testMQTTMessage) Qos() byte         { return 0 }
+func (m testMQTTMessage) Retained() bool    { return false }
+func (m testMQTTMessage) Topic() string     { return m.topic }
+func (m testMQTTMessage) MessageID() uint16 { return 0 }
+func (m testMQTTMessage) Payload() []byte   { return m.payload }
+func (m testMQTTMessage) Ack()              {}
+
+func TestHandleMQTTMessage(t *testing.T) {
+	// Messages are saved before they're queued
+	if err := LoadSavedMessages(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		payload string
+		// Name() of the source message, or empty if it shouldn't be queued
+		want string
+		text string
+	}{
+		{
+			name:    "acarsdec",
+			payload: `{"timestamp":1700000000.123,"station_id":"KSFO-ACARS","channel":2,"freq":131.550,"level":-24.1,"error":0,"mode":"2","label":"QQ","block_id":"3","ack":false,"tail":".N12345","flight":"UA0123","msgno":"D01A","text":"KSFOKLAX0820","end":true}`,
+			want:    "ACARSMessage",
+			text:    "KSFOKLAX0820",
+		},
+		{
+			name:    "dumpvdl2",
+			payload: `{"vdl2":{"app":{"name":"dumpvdl2","ver":"2.3.0"},"station":"KSFO-VDL","t":{"sec":1700000000,"usec":5000},"freq":136975000,"burst_len_octets":40,"hdr_bits_fixed":0,"octets_corrected_by_fec":0,"idx":0,"sig_level":-30.1,"noise_level":-45.2,"freq_skew":1.2,"avlc":{"src":{"addr":"A1B2C3","type":"Aircraft","status":"Airborne"},"dst":{"addr":"10916D","type":"Ground station"},"cr":"Command","frame_type":"I","rseq":1,"sseq":2,"poll":false,"acars":{"err":false,"crc_ok":true,"more":false,"reg":".N12345","mode":"2","label":"H1","blk_id":"5","ack":"!","flight":"UA0123","msg_num":"D02","msg_num_seq":"A","msg_text":"#M1BPOSN42321W083351,SAVOY,211841,350"}}}}`,
+			want:    "VDLM2Message",
+			text:    "#M1BPOSN42321W083351,SAVOY,211841,350",
+		},
+		{
+			name:    "dumpvdl2 without ACARS",
+			payload: `{"vdl2":{"app":{"name":"dumpvdl2","ver":"2.3.0"},"station":"KSFO-VDL","t":{"sec":1700000000,"usec":5000},"freq":136975000,"sig_level":-30.1,"avlc":{"src":{"addr":"A1B2C3","type":"Aircraft","status":"Airborne"},"dst":{"addr":"10916D","type":"Ground station"},"cr":"Response","frame_type":"S","rseq":3,"poll":false}}}`,
+			want:    "VDLM2Message",
+		},
+		{
+			name:    "dumphfdl",
+			payload: `{"hfdl":{"app":{"name":"dumphfdl","ver":"1.6.1"},"station":"KSFO-HFDL","t":{"sec":1700000000,"usec":250000},"freq":8927000,"bit_rate":1800,"sig_level":-20.5,"noise_level":-41.0,"freq_skew":0.4,"slot":"S","lpdu":{"err":false,"src":{"type":"Aircraft","id":12},"dst":{"type":"Ground station","id":3,"name":"Shannon, Ireland"},"type":{"id":13,"name":"Unnumbered data"},"hfnpdu":{"err":false,"type":{"id":255,"name":"ACARS"},"acars":{"err":false,"crc_ok":true,"more":false,"reg":".EI-DUO","mode":"2","label":"H1","blk_id":"7","ack":"!","flight":"EI0105","msg_num":"F34","msg_num_seq":"A","msg_text":"- #DFBFPN/RI:DA:EIDW:AA:KJFK"}}}}}`,
+			want:    "HFDLMessage",
+			text:    "- #DFBFPN/RI:DA:EIDW:AA:KJFK",
+		},
+		{
+			name:    "JAERO",
+			payload: `{"app":{"name":"JAERO","ver":"1.0.4.13"},"station":"SAT-1","t":{"sec":1700000000,"usec":0},"isu":{"aes":"A1B2C3","ges":"90","qno":"C1","refno":"03","acars":{"mode":"2","reg":".N12345","label":"H1","bi":"6","ack":"!","flight":"UA0123","msg_num":"S01","msg_text":"KSFOKLAX0820"}}}`,
+			want:    "SatcomMessage",
+			text:    "KSFOKLAX0820",
+		},
+		{
+			name:    "acars_router SATCOM",
+			payload: `{"timestamp":1700000000.5,"station_id":"SAT-2","freq":1545.0,"level":-50.0,"aes_id":"A1B2C3","ges_id":"90","mode":"2","label":"H1","block_id":"6","ack":false,"tail":".N12345","flight":"UA0123","msgno":"S01A","text":"KSFOKLAX0820"}`,
+			want:    "SatcomMessage",
+			text:    "KSFOKLAX0820",
+		},
+		{name: "empty object", payload: `{}`},
+		{name: "not JSON", payload: `KSFOKLAX0820`},
+		{name: "truncated", payload: `{"timestamp":1700000000.123,"station_id":"KSFO`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			HandleMQTTMessage(nil, testMQTTMessage{topic: "acars/" + tt.name, payload: []byte(tt.payload)})
+			var item APMessageQeueueItem
+			select {
+			case item = <-APMessageQueue:
+			default:
+			}
+			var got string
+			switch {
+			case item.SatcomMessage.IsSatcom():
+				got = item.SatcomMessage.Name()
+			case item.HFDLMessage != HFDLMessage{}:
+				got = item.HFDLMessage.Name()
+			case item.VDLM2Message != VDLM2Message{}:
+				got = item.VDLM2Message.Name()
+			case item.ACARSMessage != ACARSMessage{}:
+				got = item.ACARSMessage.Name()
+			}
+			if got != tt.want {
+				t.Fatalf("queued %q, want %q", got, tt.want)
+			}
+			if text := GetAPMessageCommonFieldAsString(item.APMessage, "MessageText"); text != tt.text {
+				t.Errorf("message text %q, want %q", text, tt.text)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Lines in captures can be much longer than bufio's default limit
+const maxReplayLineBytes = 16 * 1024 * 1024
+
+var (
+	ReplayAPMessageQueue = make(chan APMessageQeueueItem, 10000)
+	gzipMagicBytes       = []byte{0x1f, 0x8b}
+)
+
+// Counts of what happened during a replay
+type ReplaySummary struct {
+	Files     int
+	Lines     int
+	Malformed int
+	Replayed  map[string]int
+}
+
+// Replays captured messages from a file or directory of files, pacing them by
+// their original timestamps sped up by speed (0 means as fast as possible).
+// Blocks until every message has been processed.
+func ReplayMessages(path string, speed float64) {
+	files, err := ReplayFiles(path)
+	if err != nil {
+		log.Fatal(Attention("unable to read replay path %s: %s", path, err))
+	}
+	if len(files) == 0 {
+		log.Fatal(Attention("no files to replay in %s", path))
+	}
+	wg := HandleAPMessageQueue(ReplayAPMessageQueue)
+	summary := ReplaySummary{Replayed: map[string]int{}}
+	start := time.Now()
+	var pacer ReplayPacer
+	pacer.Speed = speed
+	for _, file := range files {
+		log.Info(Content("replaying "), Note(file))
+		if err := summary.ReplayFile(file, &pacer); err != nil {
+			log.Error(Attention("error replaying %s: %s", file, err))
+		}
+		summary.Files++
+	}
+	close(ReplayAPMessageQueue)
+	wg.Wait()
+	summary.Log(time.Since(start))
+}
+
+// Returns the file at path, or every file under path if it's a directory,
+// in name order
+func ReplayFiles(path string) (files []string, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// Reads a JSONL file, gzipped or not, and queues every message in it
+func (r *ReplaySummary) ReplayFile(path string, pacer *ReplayPacer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	var input io.Reader = reader
+	if magic, _ := reader.Peek(len(gzipMagicBytes)); bytes.Equal(magic, gzipMagicBytes) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		input = gz
+	}
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxReplayLineBytes)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		r.Lines++
+		next, err := ParseSourceMessage(line)
+		if err != nil {
+			r.Malformed++
+			log.Warn(Attention("skipping %s line %d: %s", path, lineNumber, err))
+			continue
+		}
+		pacer.Wait(SourceMessageTimestamp(next))
+		QueueSourceMessage(next, ReplayAPMessageQueue)
+		r.Replayed[next.Name()]++
+	}
+	return scanner.Err()
+}
+
+func (r ReplaySummary) Log(took time.Duration) {
+	log.Info(Success("replay finished, took %s", took.Round(time.Millisecond)))
+	log.Info(Content("files: "), Note("%d", r.Files),
+		Content(", lines: "), Note("%d", r.Lines),
+		Content(", malformed lines skipped: "), Note("%d", r.Malformed))
+	types := make([]string, 0, len(r.Replayed))
+	for t := range r.Replayed {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		log.Info(Content("%s replayed: ", t), Note("%d", r.Replayed[t]))
+	}
+	Stats.Lock()
+	defer Stats.Unlock()
+	log.Info(Content("finished: "), Note("%d", Stats.Finished),
+		Content(", filtered: "), Note("%d", Stats.Filtered))
+	reasons := make([]string, 0, len(Stats.FilteredBy))
+	for reason := range Stats.FilteredBy {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		log.Info(Content("filtered in %s: ", reason), Note("%d", Stats.FilteredBy[reason]))
+	}
+}
+
+// Sleeps between messages so they're replayed with the same spacing they
+// were originally received with, divided by Speed.
+type ReplayPacer struct {
+	Speed float64
+	// Timestamp of the first message and when it was replayed
+	first   float64
+	started time.Time
+}
+
+func (p *ReplayPacer) Wait(timestamp float64) {
+	if p.Speed <= 0 || timestamp == 0 {
+		return
+	}
+	if p.started.IsZero() {
+		p.first, p.started = timestamp, time.Now()
+		return
+	}
+	offset := time.Duration((timestamp - p.first) / p.Speed * float64(time.Second))
+	// Messages that are out of order are sent right away
+	if wait := time.Until(p.started.Add(offset)); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Returns when a message was received in seconds since the epoch, or 0 if
+// it's unknown
+func SourceMessageTimestamp(s Source) float64 {
+	switch m := s.(type) {
+	case ACARSMessage:
+		return m.Timestamp
+	case VDLM2Message:
+		return float64(m.VDL2.Timestamp.UnixTimestamp) + float64(m.VDL2.Timestamp.Microseconds)/1e6
+	case HFDLMessage:
+		return float64(m.HFDL.Timestamp.UnixTimestamp) + float64(m.HFDL.Timestamp.Microseconds)/1e6
+	case SatcomMessage:
+		if m.IsJAERO() {
+			return float64(m.Timestamp.UnixTimestamp) + float64(m.Timestamp.Microseconds)/1e6
+		}
+		return m.UnixTimestamp
+	}
+	return 0
+}
@@ -3,9 +3,9 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -25,56 +25,12 @@ func ReadSTDINAMessages() {
 	for {
 		scanner := bufio.NewScanner(os.Stdin)
 		for scanner.Scan() {
-			line := scanner.Text()
-			var anext ACARSMessage
-			var vnext VDLM2Message
-			err := json.Unmarshal([]byte(line), &anext)
+			next, err := ParseSourceMessage(scanner.Bytes())
 			if err != nil {
-				log.Fatal(Attention("error unmarshalling: %s", err))
-			}
-			err = json.Unmarshal([]byte(line), &vnext)
-			if err != nil {
-				log.Fatal(Attention("error unmarshalling: %s", err))
-			}
-			if (vnext != VDLM2Message{}) {
-				log.Info(Content("new vdlm2 message received ending in \""),
-					Note(Last20Characters(vnext.VDL2.AVLC.ACARS.MessageText)),
-					Content("\""))
-				queueLength := len(STDINAPMessageQueue)
-				nextap := vnext.Prepare()
-				if msgJson, err := json.Marshal(vnext); err == nil {
-					log.Debug(Emphasised("new vdlm2 message content "),
-						Note("(%d already in queue)", queueLength),
-						Content(": "),
-						Aside("%s", strings.ReplaceAll(string(msgJson), "\n", "\t")))
-				}
-				db.Create(&vnext)
-				STDINAPMessageQueue <- APMessageQeueueItem{
-					VDLM2Message: vnext,
-					APMessage:    nextap,
-				}
-				continue
-			}
-			if (anext != ACARSMessage{}) {
-				log.Info(Content("new acars message received ending in \""),
-					Note(Last20Characters(anext.MessageText)),
-					Content("\""))
-				queueLength := len(STDINAPMessageQueue)
-				nextap := anext.Prepare()
-				if msgJson, err := json.Marshal(anext); err == nil {
-					log.Debug(Emphasised("new acars message content "),
-						Note("(%d already in queue)", queueLength),
-						Content(": "),
-						Aside("%s", strings.ReplaceAll(string(msgJson), "\n", "\t")))
-				}
-				db.Create(&anext)
-				STDINAPMessageQueue <- APMessageQeueueItem{
-					ACARSMessage: anext,
-					APMessage:    nextap,
-				}
+				log.Error(Attention("skipping line from stdin: %s", err))
 				continue
 			}
-			log.Warn("error reading message as acars or vdlm2")
+			QueueSourceMessage(next, STDINAPMessageQueue)
 		}
 
 		// Check for any errors that occurred during scanning
@@ -85,3 +41,54 @@ func ReadSTDINAMessages() {
 		log.Warn(Attention("stdin handler exited, restarting"))
 	}
 }
+
+// Works out which kind of message a line of JSON is. SATCOM is checked first
+// since the acars_router format otherwise looks like ACARS, and ACARS is
+// checked last because nearly anything will unmarshal into it.
+func ParseSourceMessage(b []byte) (Source, error) {
+	var anext ACARSMessage
+	var vnext VDLM2Message
+	var hnext HFDLMessage
+	var snext SatcomMessage
+	if err := json.Unmarshal(b, &snext); err != nil {
+		return nil, fmt.Errorf("error unmarshalling: %w", err)
+	}
+	if snext.IsSatcom() {
+		return snext, nil
+	}
+	if err := json.Unmarshal(b, &hnext); err != nil {
+		return nil, fmt.Errorf("error unmarshalling: %w", err)
+	}
+	if (hnext != HFDLMessage{}) {
+		return hnext, nil
+	}
+	if err := json.Unmarshal(b, &vnext); err != nil {
+		return nil, fmt.Errorf("error unmarshalling: %w", err)
+	}
+	if (vnext != VDLM2Message{}) {
+		return vnext, nil
+	}
+	if err := json.Unmarshal(b, &anext); err != nil {
+		return nil, fmt.Errorf("error unmarshalling: %w", err)
+	}
+	if (anext != ACARSMessage{}) {
+		return anext, nil
+	}
+	return nil, errors.New("error reading message as acars, vdlm2, hfdl or satcom")
+}
+
+// Saves and queues a message returned from ParseSourceMessage
+func QueueSourceMessage(s Source, queue chan APMessageQeueueItem) {
+	switch next := s.(type) {
+	case ACARSMessage:
+		QueueACARSMessage(next, queue)
+	case VDLM2Message:
+		QueueVDLM2Message(next, queue)
+	case HFDLMessage:
+		QueueHFDLMessage(next, queue)
+	case SatcomMessage:
+		QueueSatcomMessage(next, queue)
+	default:
+		log.Error(Attention("unknown message type %T, not queueing", s))
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultDeduplicationWindowSeconds = 3
+
+// Fields added to messages by deduplication
+var DeduplicationFields = []string{
+	ACARSProcessorPrefix + "HeardByStations",
+}
+
+// Copies of a message heard so far
+type DuplicateMessages struct {
+	Best     APMessageQeueueItem
+	Stations []string
+	Started  time.Time
+}
+
+// Returns the message number and block ID the decoder gave the message, if
+// it gave them
+func (m APMessageQeueueItem) MessageIdentifiers() (number, block string) {
+	switch {
+	case m.VDLM2Message.VDL2.AVLC.ACARS.MessageNumber != "" || m.VDLM2Message.VDL2.AVLC.ACARS.BlockID != "":
+		a := m.VDLM2Message.VDL2.AVLC.ACARS
+		return a.MessageNumber, a.BlockID
+	case m.HFDLMessage.HFDL.LPDU.HFNPDU.ACARS.MessageNumber != "" || m.HFDLMessage.HFDL.LPDU.HFNPDU.ACARS.BlockID != "":
+		a := m.HFDLMessage.HFDL.LPDU.HFNPDU.ACARS
+		return a.MessageNumber, a.BlockID
+	case m.SatcomMessage.IsJAERO():
+		a := m.SatcomMessage.ISU.ACARS
+		return a.MessageNumber, a.BlockID
+	case m.SatcomMessage.IsSatcom():
+		return m.SatcomMessage.MessageNumber, m.SatcomMessage.BlockID
+	}
+	return m.ACARSMessage.MessageNumber, m.ACARSMessage.BlockID
+}
+
+// Returns the key that copies of the same message share, or an empty string
+// if there's too little in the message to tell copies from other messages
+func DeduplicationKey(item APMessageQeueueItem) string {
+	m := item.APMessage
+	number, block := item.MessageIdentifiers()
+	fields := []string{
+		GetAPMessageCommonFieldAsString(m, "TailCode"),
+		GetAPMessageCommonFieldAsString(m, "ICAOHex"),
+		GetAPMessageCommonFieldAsString(m, "FlightNumber"),
+		number,
+		block,
+		GetAPMessageCommonFieldAsString(m, "MessageText"),
+	}
+	// Squitters, link tests and the like don't identify the aircraft, so
+	// every one of them would look like a copy of the others
+	if fields[0] == "" && fields[1] == "" && fields[5] == "" {
+		return ""
+	}
+	return strings.Join(append(fields, GetAPMessageCommonFieldAsString(m, "Label")), "|")
+}
+
+// Returns the signal level of a message, or -Inf if it's unknown so any
+// copy with a signal level is preferred.
+func SignalLevel(m APMessage) float64 {
+	level := GetAPMessageCommonFieldAsFloat64(m, "SignalLeveldBm")
+	if level == 0 {
+		return math.Inf(-1)
+	}
+	return level
+}
+
+// Holds every message for the deduplication window, combining any copies
+// heard in that time into the copy with the best signal. The returned
+// channel is closed after in is closed and any held messages have been sent.
+func DeduplicateMessages(in chan APMessageQeueueItem) chan APMessageQeueueItem {
+	dc := config.ACARSProcessorSettings.Deduplication
+	if !dc.Enabled {
+		return in
+	}
+	window := time.Duration(dc.WindowSeconds * float64(time.Second))
+	if window <= 0 {
+		window = defaultDeduplicationWindowSeconds * time.Second
+	}
+	out := make(chan APMessageQeueueItem, cap(in))
+	go func() {
+		defer close(out)
+		pending := map[string]*DuplicateMessages{}
+		ticker := time.NewTicker(window / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					for key, d := range pending {
+						out <- d.Combine()
+						delete(pending, key)
+					}
+					return
+				}
+				key := DeduplicationKey(item)
+				if key == "" {
+					out <- item
+					continue
+				}
+				station := GetAPMessageCommonFieldAsString(item.APMessage, "StationId")
+				d, ok := pending[key]
+				if !ok {
+					pending[key] = &DuplicateMessages{Best: item, Stations: []string{station}, Started: time.Now()}
+					continue
+				}
+				// A station doesn't hear its own copy twice, so this is the
+				// aircraft sending the message again
+				if slices.Contains(d.Stations, station) {
+					out <- d.Combine()
+					pending[key] = &DuplicateMessages{Best: item, Stations: []string{station}, Started: time.Now()}
+					continue
+				}
+				d.Stations = append(d.Stations, station)
+				// The copy that isn't kept is done with
+				if SignalLevel(item.APMessage) > SignalLevel(d.Best.APMessage) {
+					d.Best, item = item, d.Best
+				}
+				log.Debug(Aside("dropping copy of message from %s", GetAPMessageCommonFieldAsString(item.APMessage, "StationId")))
+				item.MarkProcessed()
+			case now := <-ticker.C:
+				for key, d := range pending {
+					if now.Sub(d.Started) >= window {
+						out <- d.Combine()
+						delete(pending, key)
+					}
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Returns the best copy with the stations that heard it
+func (d *DuplicateMessages) Combine() APMessageQeueueItem {
+	stations := slices.DeleteFunc(slices.Clone(d.Stations), func(s string) bool { return s == "" })
+	slices.Sort(stations)
+	d.Best.APMessage[ACARSProcessorPrefix+"HeardByStations"] = stations
+	if len(stations) > 1 {
+		log.Info(Content("message ending in \""), Note(Last20Characters(GetAPMessageCommonFieldAsString(d.Best.APMessage, "MessageText"))),
+			Content("\" was heard by "), Note(strings.Join(stations, ", ")))
+	}
+	return d.Best
+}
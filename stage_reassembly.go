@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultReassemblyTimeoutSeconds = 30
+
+// Fields added to messages that were built from more than one block
+var ReassemblyFields = []string{
+	ACARSProcessorPrefix + "BlockCount",
+	ACARSProcessorPrefix + "ReassemblyComplete",
+}
+
+// Where a message fits in a multi-block message
+type MessageBlock struct {
+	// Identifies the message the block belongs to, per station so copies
+	// from other stations are left for deduplication
+	Key string
+	// Block sequence letter, A for the first block
+	Sequence string
+	// Whether more blocks are coming after this one
+	More bool
+}
+
+// Blocks received so far for a message
+type PendingMessage struct {
+	Blocks    map[string]APMessageQeueueItem
+	Started   time.Time
+	Sequences []string
+	// Sequence letter of the last block, once it's arrived
+	Last string
+}
+
+// Returns where a message fits in a multi-block message, ok is false if there
+// isn't enough information to tell.
+func (m APMessageQeueueItem) Block() (b MessageBlock, ok bool) {
+	switch {
+	case m.ACARSMessage.IsBlock():
+		a := m.ACARSMessage
+		// acarsdec only sets end on the last block
+		return MessageBlock{
+			Key:      strings.Join([]string{a.Name(), a.StationID, strings.TrimLeft(a.AircraftTailCode, "."), a.MessageNumber[:3]}, "|"),
+			Sequence: a.MessageNumber[3:],
+			More:     a.End == nil || !*a.End,
+		}, true
+	case m.VDLM2Message.VDL2.AVLC.ACARS.MessageNumber != "":
+		v := m.VDLM2Message.VDL2.AVLC
+		return MessageBlock{
+			Key:      strings.Join([]string{m.VDLM2Message.Name(), m.VDLM2Message.VDL2.Station, v.Source.Address, v.ACARS.Registration, v.ACARS.MessageNumber}, "|"),
+			Sequence: v.ACARS.MessageNumberSequence,
+			More:     v.ACARS.More,
+		}, true
+	case m.HFDLMessage.HFDL.LPDU.HFNPDU.ACARS.MessageNumber != "":
+		h := m.HFDLMessage.HFDL.LPDU
+		return MessageBlock{
+			Key:      strings.Join([]string{m.HFDLMessage.Name(), m.HFDLMessage.HFDL.Station, h.HFNPDU.ACARS.Registration, h.HFNPDU.ACARS.FlightNumber, h.HFNPDU.ACARS.MessageNumber}, "|"),
+			Sequence: h.HFNPDU.ACARS.MessageNumberSequence,
+			More:     h.HFNPDU.ACARS.More,
+		}, true
+	}
+	return b, false
+}
+
+// Returns true if the message number ends in a block sequence letter, like
+// M01A
+func (a ACARSMessage) IsBlock() bool {
+	return len(a.MessageNumber) == 4 && a.MessageNumber[3] >= 'A' && a.MessageNumber[3] <= 'Z'
+}
+
+// Holds blocks of multi-block messages until the last block arrives (or the
+// timeout passes) and sends them on as one message. Everything else is passed
+// through right away. The returned channel is closed after in is closed and
+// any held blocks have been sent.
+func ReassembleMessages(in chan APMessageQeueueItem) chan APMessageQeueueItem {
+	rc := config.ACARSProcessorSettings.Reassembly
+	if !rc.Enabled {
+		return in
+	}
+	timeout := time.Duration(rc.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultReassemblyTimeoutSeconds * time.Second
+	}
+	out := make(chan APMessageQeueueItem, cap(in))
+	go func() {
+		defer close(out)
+		pending := map[string]*PendingMessage{}
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					for key, p := range pending {
+						out <- p.Combine(false)
+						delete(pending, key)
+					}
+					return
+				}
+				b, ok := item.Block()
+				p, waiting := pending[b.Key]
+				// Single-block messages don't need to wait, but a last block
+				// heard before the ones ahead of it does
+				if !ok || (!waiting && !b.More && (b.Sequence == "" || b.Sequence == "A")) {
+					out <- item
+					continue
+				}
+				if !waiting {
+					p = &PendingMessage{Blocks: map[string]APMessageQeueueItem{}, Started: time.Now()}
+					pending[b.Key] = p
+				}
+				if _, seen := p.Blocks[b.Sequence]; seen {
+					// Retransmitted, or heard twice by the same station
+					log.Debug(Aside("dropping repeated block %s of %s", b.Sequence, b.Key))
+					item.MarkProcessed()
+				} else {
+					p.Blocks[b.Sequence] = item
+					p.Sequences = append(p.Sequences, b.Sequence)
+					log.Debug(Aside("holding block %s of %s", b.Sequence, b.Key))
+				}
+				if !b.More {
+					p.Last = b.Sequence
+				}
+				if p.Complete() {
+					out <- p.Combine(true)
+					delete(pending, b.Key)
+				}
+			case now := <-ticker.C:
+				for key, p := range pending {
+					if now.Sub(p.Started) >= timeout {
+						log.Info(Attention("timed out waiting for the rest of %s, processing %d blocks", key, len(p.Blocks)))
+						out <- p.Combine(false)
+						delete(pending, key)
+					}
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Returns true once the last block and every block before it have arrived
+func (p *PendingMessage) Complete() bool {
+	if len(p.Last) != 1 || p.Last[0] < 'A' {
+		return false
+	}
+	sort.Strings(p.Sequences)
+	if len(p.Sequences) != int(p.Last[0]-'A')+1 {
+		return false
+	}
+	for i, seq := range p.Sequences {
+		if seq != string(rune('A'+i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns one message with the text of every block in sequence order. The
+// first block is used for everything but the text, the rest are marked
+// processed since they're now part of it.
+func (p *PendingMessage) Combine(complete bool) APMessageQeueueItem {
+	sort.Strings(p.Sequences)
+	var text strings.Builder
+	for _, seq := range p.Sequences {
+		text.WriteString(GetAPMessageCommonFieldAsString(p.Blocks[seq].APMessage, "MessageText"))
+	}
+	combined := p.Blocks[p.Sequences[0]]
+	for _, seq := range p.Sequences[1:] {
+		p.Blocks[seq].MarkProcessed()
+	}
+	combined.SetMessageText(text.String())
+	combined.APMessage[ACARSProcessorPrefix+"BlockCount"] = len(p.Sequences)
+	combined.APMessage[ACARSProcessorPrefix+"ReassemblyComplete"] = complete
+	if len(p.Sequences) > 1 {
+		log.Info(Content("reassembled message ending in \""), Note(Last20Characters(text.String())),
+			Content("\" from "), Note("%d", len(p.Sequences)), Content(" blocks"))
+	}
+	return combined
+}
+
+// Replaces the message text in the source message (so it's saved) and in
+// the fields derived from it.
+func (m *APMessageQeueueItem) SetMessageText(text string) {
+	var field string
+	switch {
+	case m.ACARSMessage.IsBlock():
+		m.ACARSMessage.MessageText = text
+		field = fmt.Sprintf("%s.MessageText", m.ACARSMessage.Name())
+	case m.VDLM2Message.VDL2.AVLC.ACARS.MessageNumber != "":
+		m.VDLM2Message.VDL2.AVLC.ACARS.MessageText = text
+		field = fmt.Sprintf("%s.VDL2.AVLC.ACARS.MessageText", m.VDLM2Message.Name())
+	case m.HFDLMessage.HFDL.LPDU.HFNPDU.ACARS.MessageNumber != "":
+		m.HFDLMessage.HFDL.LPDU.HFNPDU.ACARS.MessageText = text
+		field = fmt.Sprintf("%s.HFDL.LPDU.HFNPDU.ACARS.MessageText", m.HFDLMessage.Name())
+	}
+	// Only replace fields that weren't removed by SelectedFields
+	for _, f := range []string{field, ACARSProcessorPrefix + "MessageText"} {
+		if _, ok := m.APMessage[f]; ok {
+			m.APMessage[f] = text
+		}
+	}
+	if _, ok := m.APMessage[ACARSProcessorPrefix+"TranslateLink"]; ok {
+		m.APMessage[ACARSProcessorPrefix+"TranslateLink"] = fmt.Sprintf(GoogleTranslateLink, url.QueryEscape(text))
+	}
+}
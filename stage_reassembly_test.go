@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// acarsdec blocks of an H1 position report. acarsdec only writes end on the
+// last block.
+const (
+	testBlockA = `{"timestamp":1700000000.1,"station_id":"KSFO-ACARS","freq":131.550,"level":-24.1,"mode":"2","label":"H1","block_id":"3","tail":".N12345","flight":"UA0123","msgno":"M01A","text":"#M1BPOSN42321W083351,"}`
+	testBlockB = `{"timestamp":1700000001.2,"station_id":"KSFO-ACARS","freq":131.550,"level":-24.3,"mode":"2","label":"H1","block_id":"4","tail":".N12345","flight":"UA0123","msgno":"M01B","text":"SAVOY,211841,350","end":true}`
+	// The same blocks heard by another station
+	testBlockAOAK = `{"timestamp":1700000000.1,"station_id":"KOAK-ACARS","freq":131.550,"level":-18.0,"mode":"2","label":"H1","block_id":"3","tail":".N12345","flight":"UA0123","msgno":"M01A","text":"#M1BPOSN42321W083351,"}`
+	testBlockBOAK = `{"timestamp":1700000001.2,"station_id":"KOAK-ACARS","freq":131.550,"level":-18.2,"mode":"2","label":"H1","block_id":"4","tail":".N12345","flight":"UA0123","msgno":"M01B","text":"SAVOY,211841,350","end":true}`
+
+	testReassembledText = "#M1BPOSN42321W083351,SAVOY,211841,350"
+)
+
+// Sends acarsdec payloads through reassembly and returns the first want
+// messages out of it, failing if they don't come out within wait or if more
+// come out once the input is closed
+func reassembleACARS(t *testing.T, payloads []string, want int, wait time.Duration) (out []APMessageQeueueItem) {
+	t.Helper()
+	// Blocks after the first are marked processed in the database
+	if err := LoadSavedMessages(); err != nil {
+		t.Fatal(err)
+	}
+	config.ACARSProcessorSettings.Reassembly = ReassemblyConfig{Enabled: true, TimeoutSeconds: 1}
+	defer func() { config.ACARSProcessorSettings.Reassembly = ReassemblyConfig{} }()
+
+	in := make(chan APMessageQeueueItem, len(payloads))
+	reassembled := ReassembleMessages(in)
+	for _, p := range payloads {
+		var a ACARSMessage
+		if err := json.Unmarshal([]byte(p), &a); err != nil {
+			t.Fatal(err)
+		}
+		// Sources save messages before queueing them
+		if err := db.Create(&a).Error; err != nil {
+			t.Fatal(err)
+		}
+		in <- APMessageQeueueItem{ACARSMessage: a, APMessage: a.Prepare()}
+	}
+	for range want {
+		select {
+		case item := <-reassembled:
+			out = append(out, item)
+		case <-time.After(wait):
+			t.Fatalf("got %d messages, want %d", len(out), want)
+		}
+	}
+	close(in)
+	if item, ok := <-reassembled; ok {
+		t.Errorf("extra message %q", GetAPMessageCommonFieldAsString(item.APMessage, "MessageText"))
+	}
+	return out
+}
+
+// Checks the text, block count and completeness of a reassembled message
+func checkReassembled(t *testing.T, item APMessageQeueueItem, text string, blocks int, complete bool) {
+	t.Helper()
+	if got := GetAPMessageCommonFieldAsString(item.APMessage, "MessageText"); got != text {
+		t.Errorf("message text %q, want %q", got, text)
+	}
+	if n := item.APMessage[ACARSProcessorPrefix+"BlockCount"]; n != blocks {
+		t.Errorf("block count %v, want %d", n, blocks)
+	}
+	if c := item.APMessage[ACARSProcessorPrefix+"ReassemblyComplete"]; c != complete {
+		t.Errorf("reassembly complete %v, want %v", c, complete)
+	}
+}
+
+func TestReassembleACARSBlocks(t *testing.T) {
+	out := reassembleACARS(t, []string{testBlockA, testBlockB}, 1, 500*time.Millisecond)
+	checkReassembled(t, out[0], testReassembledText, 2, true)
+}
+
+func TestReassembleACARSBlocksOutOfOrder(t *testing.T) {
+	out := reassembleACARS(t, []string{testBlockB, testBlockA}, 1, 500*time.Millisecond)
+	checkReassembled(t, out[0], testReassembledText, 2, true)
+}
+
+func TestReassembleACARSBlocksFromTwoStations(t *testing.T) {
+	// Interleaved like they'd arrive, and with a block retransmitted
+	out := reassembleACARS(t, []string{testBlockA, testBlockAOAK, testBlockA, testBlockBOAK, testBlockB}, 2, 500*time.Millisecond)
+	stations := map[string]bool{}
+	for _, item := range out {
+		checkReassembled(t, item, testReassembledText, 2, true)
+		stations[GetAPMessageCommonFieldAsString(item.APMessage, "StationId")] = true
+	}
+	// Both go on to deduplication
+	if !stations["KSFO-ACARS"] || !stations["KOAK-ACARS"] {
+		t.Errorf("messages from %v, want one from each station", stations)
+	}
+}
+
+func TestReassembleACARSBlocksTimeout(t *testing.T) {
+	start := time.Now()
+	out := reassembleACARS(t, []string{testBlockA}, 1, 3*time.Second)
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("sent after %s, before the timeout", waited)
+	}
+	checkReassembled(t, out[0], "#M1BPOSN42321W083351,", 1, false)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const defaultThreadingTimeoutMinutes = 15
+
+// Fields added to messages that are part of a conversation
+var ThreadingFields = []string{
+	ACARSProcessorPrefix + "ThreadID",
+	ACARSProcessorPrefix + "ThreadPosition",
+	ACARSProcessorPrefix + "ThreadPreviousMessageText",
+	ACARSProcessorPrefix + "ThreadPreviousMessageFrom",
+}
+
+// Locks for conversations receivers are posting, keyed by thread ID
+var (
+	conversationPostLocks     = map[uint]*conversationPostLock{}
+	conversationPostLocksLock sync.Mutex
+)
+
+type conversationPostLock struct {
+	sync.Mutex
+	// Workers holding or waiting for the lock
+	users int
+}
+
+// Messages to and from an aircraft that are part of the same exchange
+type ConversationThread struct {
+	gorm.Model
+	TailCode        string `gorm:"index"`
+	FlightNumber    string
+	MessageCount    int
+	LastMessageAt   time.Time
+	LastMessageText string
+	LastMessageFrom string
+	LastBlockID     string
+}
+
+// Where a receiver posted a conversation, so later messages can go to the
+// same place
+type ConversationPost struct {
+	gorm.Model
+	ConversationThreadID uint   `gorm:"index"`
+	Receiver             string `gorm:"index"`
+	// Discord thread ID or the last Mastodon status ID in the reply chain
+	PostID string
+}
+
+// Adds each message to a conversation with the other messages to and from
+// the same aircraft. The returned channel is closed after in is closed.
+func ThreadMessages(in chan APMessageQeueueItem) chan APMessageQeueueItem {
+	tc := config.ACARSProcessorSettings.Threading
+	if !tc.Enabled {
+		return in
+	}
+	out := make(chan APMessageQeueueItem, cap(in))
+	go func() {
+		defer close(out)
+		for item := range in {
+			item.Thread(tc)
+			out <- item
+		}
+	}()
+	return out
+}
+
+// Finds or starts the conversation for the message and adds the thread
+// fields to it. A message continues the aircraft's last conversation if it's
+// within the timeout or it acknowledges the last message from the other side.
+func (m *APMessageQeueueItem) Thread(tc ThreadingConfig) {
+	tail := GetAPMessageCommonFieldAsString(m.APMessage, "TailCode")
+	text := GetAPMessageCommonFieldAsString(m.APMessage, "MessageText")
+	label := GetAPMessageCommonFieldAsString(m.APMessage, "Label")
+	if tail == "" || text == "" || (len(tc.Labels) > 0 && !slices.Contains(tc.Labels, label)) {
+		return
+	}
+	timeout := time.Duration(tc.TimeoutMinutes) * time.Minute
+	if timeout <= 0 {
+		timeout = defaultThreadingTimeoutMinutes * time.Minute
+	}
+	at := time.Now()
+	if ts := GetAPMessageCommonFieldAsInt64(m.APMessage, "UnixTimestamp"); ts != 0 {
+		at = time.Unix(ts, 0)
+	}
+	from := GetAPMessageCommonFieldAsString(m.APMessage, "From")
+	blockID, ack := m.BlockIDAndAcknowledgement()
+
+	var t ConversationThread
+	found := db.Where("tail_code = ?", tail).Order("last_message_at desc").Limit(1).Find(&t).RowsAffected > 0
+	acknowledges := ack != "" && ack == t.LastBlockID && from != t.LastMessageFrom
+	if !found || (at.Sub(t.LastMessageAt) > timeout && !acknowledges) {
+		t = ConversationThread{TailCode: tail}
+	}
+	previousText, previousFrom := t.LastMessageText, t.LastMessageFrom
+	t.MessageCount++
+	t.LastMessageAt = at
+	t.LastMessageText = text
+	t.LastMessageFrom = from
+	t.LastBlockID = blockID
+	if flight := GetAPMessageCommonFieldAsString(m.APMessage, "FlightNumber"); flight != "" {
+		t.FlightNumber = flight
+	}
+	if err := db.Save(&t).Error; err != nil {
+		log.Error(Attention("unable to save conversation for %s: %s", tail, err))
+		return
+	}
+	m.APMessage[ACARSProcessorPrefix+"ThreadID"] = t.ID
+	m.APMessage[ACARSProcessorPrefix+"ThreadPosition"] = t.MessageCount
+	m.APMessage[ACARSProcessorPrefix+"ThreadPreviousMessageText"] = previousText
+	m.APMessage[ACARSProcessorPrefix+"ThreadPreviousMessageFrom"] = previousFrom
+	if t.MessageCount > 1 {
+		log.Debug(Aside("message from %s is number %d in conversation %d with %s", from, t.MessageCount, t.ID, tail))
+	}
+}
+
+// Returns the block ID of the message and the block ID it acknowledges, if
+// any
+func (m APMessageQeueueItem) BlockIDAndAcknowledgement() (blockID, ack string) {
+	var a any
+	switch {
+	case !reflect.DeepEqual(m.ACARSMessage, ACARSMessage{}):
+		blockID, a = m.ACARSMessage.BlockID, m.ACARSMessage.Acknowledge
+	case !reflect.DeepEqual(m.VDLM2Message, VDLM2Message{}):
+		acars := m.VDLM2Message.VDL2.AVLC.ACARS
+		blockID, a = acars.BlockID, acars.Acknowledge
+	case !reflect.DeepEqual(m.HFDLMessage, HFDLMessage{}):
+		acars := m.HFDLMessage.HFDL.LPDU.HFNPDU.ACARS
+		blockID, a = acars.BlockID, acars.Acknowledge
+	case m.SatcomMessage.IsJAERO():
+		blockID, a = m.SatcomMessage.ISU.ACARS.BlockID, m.SatcomMessage.ISU.ACARS.Acknowledge
+	default:
+		blockID, a = m.SatcomMessage.BlockID, m.SatcomMessage.Acknowledge
+	}
+	// Decoders send false, "!" or NAK when the message doesn't acknowledge
+	// anything
+	switch ack = fmt.Sprint(a); ack {
+	case "<nil>", "false", "!", "\u0015":
+		ack = ""
+	}
+	return blockID, ack
+}
+
+// Returns the conversation ID of a message, 0 if it isn't part of one
+func ConversationThreadID(m APMessage) uint {
+	id, _ := m[ACARSProcessorPrefix+"ThreadID"].(uint)
+	return id
+}
+
+// Receivers hold the conversation's lock while looking up and saving where
+// they posted it so two workers don't start the same thread twice. Other
+// conversations aren't held up. Call the returned function to unlock it.
+func LockConversationPost(threadID uint) (unlock func()) {
+	conversationPostLocksLock.Lock()
+	l, ok := conversationPostLocks[threadID]
+	if !ok {
+		l = &conversationPostLock{}
+		conversationPostLocks[threadID] = l
+	}
+	l.users++
+	conversationPostLocksLock.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		conversationPostLocksLock.Lock()
+		defer conversationPostLocksLock.Unlock()
+		if l.users--; l.users == 0 {
+			delete(conversationPostLocks, threadID)
+		}
+	}
+}
+
+// Returns where the receiver posted the conversation before. PostID is empty
+// if it hasn't yet. Callers should hold the lock from LockConversationPost.
+func FindConversationPost(threadID uint, receiver string) (p ConversationPost) {
+	db.Where(ConversationPost{ConversationThreadID: threadID, Receiver: receiver}).FirstOrInit(&p)
+	return p
+}
+
+func SaveConversationPost(p ConversationPost) {
+	if err := db.Save(&p).Error; err != nil {
+		log.Error(Attention("unable to save where %s posted conversation %d: %s", p.Receiver, p.ConversationThreadID, err))
+	}
+}
+
+// Identifies a configured receiver without saving its secrets
+func ConversationReceiverKey(name, identity string) string {
+	sum := sha256.Sum256([]byte(identity))
+	return name + " " + hex.EncodeToString(sum[:8])
+}